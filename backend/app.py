@@ -5,35 +5,190 @@ Developed with Claude AI using Claude Code
 """
 
 import os
+import re
+import json
 import secrets
-from datetime import datetime, timedelta
-from flask import Flask, request, jsonify
+import hashlib
+import threading
+from functools import wraps
+from contextlib import contextmanager
+from enum import IntEnum
+from datetime import datetime, timedelta, timezone
+from zoneinfo import ZoneInfo, ZoneInfoNotFoundError
+from flask import Flask, request, jsonify, send_from_directory, stream_with_context
+from werkzeug.exceptions import RequestEntityTooLarge
 from flask_cors import CORS
-from flask_jwt_extended import JWTManager, create_access_token, jwt_required, get_jwt_identity
+from flask_jwt_extended import (
+    JWTManager, create_access_token, decode_token, jwt_required, get_jwt, get_jwt_identity,
+    set_access_cookies, unset_jwt_cookies
+)
 import psycopg2
 from psycopg2.extras import RealDictCursor
 import bcrypt
+from cryptography.hazmat.primitives import serialization
+from jwt.algorithms import RSAAlgorithm
 from dotenv import load_dotenv
-from marshmallow import Schema, fields, ValidationError
+from marshmallow import Schema, fields, validate, ValidationError, validates_schema, pre_load, EXCLUDE
 from oidc_client import create_oidc_client
 from user_sync import sync_user_with_oidc, UserSyncManager
+from mailer import create_mailer
+from storage import create_image_storage, LocalImageStorage
+from openapi import init_api_docs
+from metrics import init_metrics, register_pool_metrics, DB_CONNECTION_ERRORS, DB_CONNECTION_WAIT
+from psycopg2.pool import PoolError
+from db_pool import create_connection_pool, wait_for_database
+from migrate import run_migrations, MigrationError
+from jobs import BackgroundJobs, ConnectionJobs
+from webhooks import WEBHOOK_EVENTS, UnsafeWebhookURL, create_webhook_dispatcher
+from ical import build_calendar
 
 # Load environment variables
 load_dotenv()
 
 app = Flask(__name__)
 
+def read_key(env_name):
+    """Read a PEM key from <NAME>_PATH if set, otherwise from <NAME> itself"""
+    path = os.getenv(f'{env_name}_PATH')
+    if path:
+        with open(path) as key_file:
+            return key_file.read()
+    return os.getenv(env_name)
+
 # Configuration
 app.config['JWT_SECRET_KEY'] = os.getenv('JWT_SECRET', 'your-super-secret-jwt-key-change-this-in-production')
 app.config['JWT_ACCESS_TOKEN_EXPIRES'] = timedelta(days=7)
 
+# Signing algorithm: HS256 with JWT_SECRET (default) or RS256 with an RSA key pair.
+# Only the configured algorithm is accepted when decoding, so a token can't pick
+# its own verification method (e.g. an HS256 token signed with the public key).
+JWT_ALGORITHM = os.getenv('JWT_ALGORITHM', 'HS256')
+if JWT_ALGORITHM not in ('HS256', 'RS256'):
+    raise ValueError(f"Unsupported JWT_ALGORITHM: {JWT_ALGORITHM}")
+app.config['JWT_ALGORITHM'] = JWT_ALGORITHM
+app.config['JWT_DECODE_ALGORITHMS'] = [JWT_ALGORITHM]
+if JWT_ALGORITHM == 'RS256':
+    app.config['JWT_PRIVATE_KEY'] = read_key('JWT_PRIVATE_KEY')
+    app.config['JWT_PUBLIC_KEY'] = read_key('JWT_PUBLIC_KEY')
+    if not app.config['JWT_PRIVATE_KEY'] or not app.config['JWT_PUBLIC_KEY']:
+        raise ValueError("JWT_ALGORITHM=RS256 requires JWT_PRIVATE_KEY and JWT_PUBLIC_KEY (or their _PATH variants)")
+
+# Tokens are read from the Authorization header first, then from the HttpOnly cookie
+# set when a client logs in with use_cookie (or AUTH_COOKIE_DEFAULT=true)
+app.config['JWT_TOKEN_LOCATION'] = ['headers', 'cookies']
+app.config['JWT_COOKIE_SECURE'] = os.getenv('JWT_COOKIE_SECURE', str(os.getenv('NODE_ENV') == 'production')).lower() == 'true'
+app.config['JWT_COOKIE_SAMESITE'] = os.getenv('JWT_COOKIE_SAMESITE', 'Lax')
+app.config['JWT_COOKIE_CSRF_PROTECT'] = os.getenv('JWT_COOKIE_CSRF_PROTECT', 'true').lower() == 'true'
+AUTH_COOKIE_DEFAULT = os.getenv('AUTH_COOKIE_DEFAULT', 'false').lower() == 'true'
+
+# Sliding expiration: tokens used within this window of expiring are renewed and the
+# new token is returned in the X-Refreshed-Token response header (0 disables renewal)
+TOKEN_REFRESH_THRESHOLD = timedelta(hours=int(os.getenv('TOKEN_REFRESH_THRESHOLD_HOURS', 24)))
+
+# Password hashing cost; raising it upgrades existing hashes on their next login
+BCRYPT_ROUNDS = int(os.getenv('BCRYPT_ROUNDS', 12))
+
+# Email verification
+EMAIL_VERIFICATION_TTL = timedelta(hours=int(os.getenv('EMAIL_VERIFICATION_TTL_HOURS', 48)))
+REQUIRE_VERIFIED_EMAIL_FOR_SHARING = os.getenv('REQUIRE_VERIFIED_EMAIL_FOR_SHARING', 'false').lower() == 'true'
+
+# Accounts with these emails are made administrators on startup and registration
+ADMIN_EMAILS = [email.strip().lower() for email in os.getenv('ADMIN_EMAILS', '').split(',') if email.strip()]
+
+# Password reset links are short-lived
+PASSWORD_RESET_TTL = timedelta(minutes=int(os.getenv('PASSWORD_RESET_TTL_MINUTES', 60)))
+
+# Item priorities, lowest first; a deployment can add levels such as "urgent"
+ITEM_PRIORITIES = [
+    priority.strip().lower()
+    for priority in os.getenv('ITEM_PRIORITIES', 'low,medium,high').split(',')
+    if priority.strip()
+]
+DEFAULT_ITEM_PRIORITY = os.getenv('DEFAULT_ITEM_PRIORITY', 'medium').strip().lower()
+if DEFAULT_ITEM_PRIORITY not in ITEM_PRIORITIES:
+    raise SystemExit(f"DEFAULT_ITEM_PRIORITY '{DEFAULT_ITEM_PRIORITY}' is not one of ITEM_PRIORITIES ({', '.join(ITEM_PRIORITIES)})")
+if any(len(priority) > 20 for priority in ITEM_PRIORITIES):
+    raise SystemExit('ITEM_PRIORITIES entries must be at most 20 characters')
+
+# Deleted items stay in the list's trash this long before being purged
+TRASH_RETENTION = timedelta(days=int(os.getenv('TRASH_RETENTION_DAYS', 30)))
+
+# Invitations a user can send per hour (each one notifies the invitee)
+INVITATIONS_PER_HOUR = int(os.getenv('INVITATIONS_PER_HOUR', 30))
+
+# Soft quotas: lists a user can own and live items a list can hold (trashed items don't count);
+# items added by collaborators count against the list they are added to
+MAX_LISTS_PER_USER = int(os.getenv('MAX_LISTS_PER_USER', 200))
+MAX_ITEMS_PER_LIST = int(os.getenv('MAX_ITEMS_PER_LIST', 1000))
+
+# Guest (demo) accounts: off by default; short-lived tokens, tighter quotas, purged once inactive
+GUEST_MODE_ENABLED = os.getenv('GUEST_MODE_ENABLED', 'false').lower() == 'true'
+GUEST_TOKEN_TTL = timedelta(hours=int(os.getenv('GUEST_TOKEN_HOURS', 24)))
+GUEST_INACTIVITY = timedelta(hours=int(os.getenv('GUEST_INACTIVITY_HOURS', 24)))
+GUESTS_PER_HOUR = int(os.getenv('GUESTS_PER_HOUR', 5))  # per client IP
+GUEST_MAX_LISTS = int(os.getenv('GUEST_MAX_LISTS', 3))
+GUEST_MAX_ITEMS_PER_LIST = int(os.getenv('GUEST_MAX_ITEMS_PER_LIST', 50))
+
+# Idempotency-Key responses are replayed for this long
+IDEMPOTENCY_KEY_TTL = timedelta(hours=int(os.getenv('IDEMPOTENCY_KEY_TTL_HOURS', 24)))
+
+# Outgoing email (replace with MemoryMailer in tests)
+mailer = create_mailer()
+
+# Item photo uploads
+image_storage = create_image_storage()
+IMAGE_MAX_BYTES = int(os.getenv('IMAGE_MAX_BYTES', 5 * 1024 * 1024))
+# Allowed image types mapped to the file extension they are stored with
+IMAGE_CONTENT_TYPES = {
+    'image/jpeg': 'jpg',
+    'image/png': 'png',
+    'image/gif': 'gif',
+    'image/webp': 'webp'
+}
+
+def sniff_image_extension(data):
+    """Extension for an allowed image type recognized from its leading bytes, or None"""
+    if data.startswith(b'\xff\xd8\xff'):
+        return 'jpg'
+    if data.startswith(b'\x89PNG\r\n\x1a\n'):
+        return 'png'
+    if data[:6] in (b'GIF87a', b'GIF89a'):
+        return 'gif'
+    if data[:4] == b'RIFF' and data[8:12] == b'WEBP':
+        return 'webp'
+    return None
+
+# Largest accepted request body (413 beyond it); image uploads are bounded by IMAGE_MAX_BYTES instead
+MAX_REQUEST_BYTES = int(os.getenv('MAX_REQUEST_BYTES', 1024 * 1024))
+# Hard cap enforced while reading any body, leaving room for multipart overhead on uploads
+app.config['MAX_CONTENT_LENGTH'] = max(MAX_REQUEST_BYTES, IMAGE_MAX_BYTES + 64 * 1024)
+
+def parse_cors_origins(value):
+    """
+    Parse a comma-separated origin list
+    Entries like https://*.example.com match any single subdomain
+    """
+    origins = []
+    for origin in value.split(','):
+        origin = origin.strip().rstrip('/')
+        if not origin:
+            continue
+        if '*' in origin and origin != '*':
+            pattern = re.escape(origin).replace(r'\*', r'[^./]+')
+            origins.append(re.compile(f'^{pattern}$'))
+        else:
+            origins.append(origin)
+    return origins
+
+# Allowed CORS origins (defaults to the frontend URL)
+CORS_ALLOWED_ORIGINS = parse_cors_origins(
+    os.getenv('CORS_ALLOWED_ORIGINS') or os.getenv('FRONTEND_URL', 'http://localhost:3000')
+)
+
 # Initialize extensions
 jwt = JWTManager(app)
-CORS(app, origins=[
-    os.getenv('FRONTEND_URL', 'http://localhost:3000'),
-    'http://localhost:3000',
-    'http://192.168.1.27:3000'
-])
+CORS(app, supports_credentials=True, expose_headers=['X-Refreshed-Token', 'Idempotent-Replayed', 'ETag'], origins=CORS_ALLOWED_ORIGINS)
+init_metrics(app)
 
 # Database configuration
 DB_CONFIG = {
@@ -44,43 +199,1136 @@ DB_CONFIG = {
     'password': os.getenv('DB_PASSWORD', 'shopping_password')
 }
 
+# Placeholder secrets shipped in the example configs
+DEFAULT_JWT_SECRETS = {
+    'your-super-secret-jwt-key-change-this-in-production',
+    'your-super-secret-jwt-key-change-this-in-production-please'
+}
+DEFAULT_DB_PASSWORDS = {'shopping_password'}
+OIDC_SETTINGS = ('OIDC_CLIENT_ID', 'OIDC_CLIENT_SECRET', 'OIDC_DISCOVERY_URL', 'OIDC_REDIRECT_URI')
+
+def validate_config():
+    """Return a list of insecure or incomplete configuration settings"""
+    problems = []
+    
+    if JWT_ALGORITHM == 'HS256':
+        secret = os.getenv('JWT_SECRET', '')
+        if not secret or secret in DEFAULT_JWT_SECRETS:
+            problems.append('JWT_SECRET is empty or still set to the example value')
+    
+    if DB_CONFIG['password'] in DEFAULT_DB_PASSWORDS:
+        problems.append('DB_PASSWORD is still set to the example value')
+    
+    # OIDC counts as enabled once a client ID is configured
+    if os.getenv('OIDC_CLIENT_ID'):
+        missing = [name for name in OIDC_SETTINGS if not os.getenv(name)]
+        if missing:
+            problems.append(f"OIDC is enabled but {', '.join(missing)} {'is' if len(missing) == 1 else 'are'} not set")
+    
+    return problems
+
+# Fail fast in production; only warn during development
+config_problems = validate_config()
+if config_problems:
+    if os.getenv('NODE_ENV') == 'production':
+        raise SystemExit('Refusing to start with insecure configuration:\n' +
+                         '\n'.join(f'  - {problem}' for problem in config_problems))
+    for problem in config_problems:
+        print(f"Config warning: {problem}")
+
+# Connection pool, created on first use so each gunicorn worker opens its own
+db_pool = None
+db_pool_lock = threading.Lock()
+
+def get_db_pool():
+    global db_pool
+    if db_pool is None:
+        with db_pool_lock:
+            if db_pool is None:
+                db_pool = create_connection_pool(DB_CONFIG)
+    return db_pool
+
+def db_pool_stats():
+    """Pool statistics for the health and metrics endpoints (None before first use)"""
+    return db_pool.stats() if db_pool else None
+
+register_pool_metrics(db_pool_stats)
+
+@contextmanager
 def get_db_connection():
-    """Get database connection"""
+    """Borrow a pooled database connection; commits on success and rolls back on error"""
     try:
-        conn = psycopg2.connect(**DB_CONFIG)
-        return conn
-    except psycopg2.Error as e:
+        with DB_CONNECTION_WAIT.time():
+            pool = get_db_pool()
+            conn = pool.getconn()
+    except (psycopg2.Error, PoolError) as e:
+        DB_CONNECTION_ERRORS.inc()
         print(f"Database connection error: {e}")
         raise
+    
+    try:
+        with conn:
+            yield conn
+    finally:
+        pool.putconn(conn)
+
+# Wait for Postgres on startup (it is often still booting under docker-compose)
+DB_CONNECT_MAX_ATTEMPTS = int(os.getenv('DB_CONNECT_MAX_ATTEMPTS', 10))
+DB_CONNECT_RETRY_DELAY = float(os.getenv('DB_CONNECT_RETRY_DELAY_SECONDS', 1))
+if DB_CONNECT_MAX_ATTEMPTS > 0:
+    try:
+        wait_for_database(DB_CONFIG, DB_CONNECT_MAX_ATTEMPTS, DB_CONNECT_RETRY_DELAY)
+    except RuntimeError as e:
+        raise SystemExit(str(e))
+
+# Bring the schema up to date (set RUN_MIGRATIONS=false to run migrate.py separately)
+if os.getenv('RUN_MIGRATIONS', 'true').lower() == 'true':
+    migration_conn = psycopg2.connect(**DB_CONFIG)
+    try:
+        run_migrations(migration_conn)
+    except MigrationError as e:
+        raise SystemExit(str(e))
+    finally:
+        migration_conn.close()
+
+# Seed administrators from ADMIN_EMAILS
+if ADMIN_EMAILS:
+    with psycopg2.connect(**DB_CONFIG) as admin_conn:
+        with admin_conn.cursor() as cur:
+            cur.execute("UPDATE users SET is_admin = TRUE WHERE LOWER(email) = ANY(%s)", (ADMIN_EMAILS,))
+    admin_conn.close()
+
+# Password helpers
+def hash_password(password):
+    """Hash a password with the configured bcrypt cost"""
+    return bcrypt.hashpw(password.encode('utf-8'), bcrypt.gensalt(rounds=BCRYPT_ROUNDS)).decode('utf-8')
+
+def check_password(password, password_hash):
+    """Check a password against a stored hash; accounts without a local password never match"""
+    if not password_hash:
+        return False
+    return bcrypt.checkpw(password.encode('utf-8'), password_hash.encode('utf-8'))
+
+def password_needs_rehash(password_hash):
+    """Whether a bcrypt hash ($2b$<cost>$...) was created with a lower cost than configured"""
+    try:
+        return int(password_hash.split('$')[2]) < BCRYPT_ROUNDS
+    except (IndexError, ValueError):
+        return False
 
 # Validation schemas
 class UserRegistrationSchema(Schema):
     username = fields.Str(required=True, validate=lambda x: 3 <= len(x) <= 30)
     email = fields.Email(required=True)
     password = fields.Str(required=True, validate=lambda x: len(x) >= 6)
+    use_cookie = fields.Bool(missing=None)  # Return the token in an HttpOnly cookie
+    
+    @pre_load
+    def normalize_identity(self, data, **kwargs):
+        # Usernames keep their casing but are unique case-insensitively; emails are stored lowercase
+        if isinstance(data, dict):
+            data = dict(data)
+            if isinstance(data.get('username'), str):
+                data['username'] = data['username'].strip()
+            if isinstance(data.get('email'), str):
+                data['email'] = data['email'].strip().lower()
+        return data
 
 class UserLoginSchema(Schema):
     login = fields.Str(required=True)  # Can be email or username
     password = fields.Str(required=True)
+    use_cookie = fields.Bool(missing=None)  # Return the token in an HttpOnly cookie
+    
+    @pre_load
+    def normalize_login(self, data, **kwargs):
+        if isinstance(data, dict) and isinstance(data.get('login'), str):
+            data = dict(data, login=data['login'].strip())
+        return data
+
+class ForgotPasswordSchema(Schema):
+    email = fields.Email(required=True)
+
+class ResetPasswordSchema(Schema):
+    token = fields.Str(required=True)
+    password = fields.Str(required=True, validate=lambda x: len(x) >= 6)
+
+def parse_timezone(name):
+    """ZoneInfo for an IANA timezone name; raises ValueError with a client-facing message"""
+    try:
+        return ZoneInfo(name)
+    except (ZoneInfoNotFoundError, ValueError):
+        raise ValueError(f'Unknown timezone: {name}')
+
+def validate_timezone(value):
+    try:
+        parse_timezone(value)
+    except ValueError:
+        raise ValidationError('Must be an IANA timezone such as Europe/Prague.')
+
+class AccountDeletionSchema(Schema):
+    # Required for accounts with a local password
+    password = fields.Str()
+    # Confirms deleting shared lists that have no admin collaborator to take them over
+    delete_shared_lists = fields.Bool(missing=False)
+
+class UserPreferencesSchema(Schema):
+    timezone = fields.Str(allow_none=True, validate=validate_timezone)
+    locale = fields.Str(allow_none=True, validate=validate.Regexp(
+        r'^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$', error='Must be a language tag such as en or cs-CZ.'))
+    # Opt in to the periodic digest of lists with items left
+    digest_enabled = fields.Bool()
+
+# Categories are stored lowercase; input is trimmed and lowercased before validation
+ITEM_CATEGORIES = [
+    'produce', 'dairy', 'meat', 'pantry', 'frozen', 
+    'bakery', 'beverages', 'snacks', 'household', 'health'
+]
+
+def normalize_category(value):
+    return value.strip().lower() if isinstance(value, str) else value
+
+def strip_text(value):
+    return value.strip() if isinstance(value, str) else value
+
+# Names are trimmed before validation, so whitespace-only names are rejected
+NAME_LENGTH = validate.Length(min=1, max=255, error='Must not be blank and must be at most {max} characters.')
 
 class ShoppingListItemSchema(Schema):
-    name = fields.Str(required=True, validate=lambda x: 1 <= len(x) <= 255)
-    quantity = fields.Int(missing=1, validate=lambda x: x >= 1)
-    category = fields.Str(required=True, validate=lambda x: x in [
-        'produce', 'dairy', 'meat', 'pantry', 'frozen', 
-        'bakery', 'beverages', 'snacks', 'household', 'health'
-    ])
-    priority = fields.Str(missing='low', validate=lambda x: x in ['low', 'medium', 'high'])
+    name = fields.Str(required=True, validate=NAME_LENGTH)
+    quantity = fields.Int(missing=1, validate=lambda x: x >= 1)  # Omitted, null or 0 means 1
+    # Free-text amount shown instead of quantity when set ("2-3", "to taste"); quantity is still used for totals
+    amount = fields.Str(allow_none=True, validate=validate.Length(max=50))
+    category = fields.Str(required=True, validate=lambda x: x in ITEM_CATEGORIES)
+    priority = fields.Str(missing=DEFAULT_ITEM_PRIORITY, validate=lambda x: x in ITEM_PRIORITIES)  # Omitted, null or empty means the default
     notes = fields.Str(missing='')
     completed = fields.Bool(missing=False)
+    # Optional fields: omit to keep the current value, null to clear
+    assigned_to = fields.Int(allow_none=True)
+    recurring = fields.Bool()
+    recur_interval_days = fields.Int(allow_none=True, validate=lambda x: 1 <= x <= 365)
+    due_date = fields.Date(allow_none=True)
+    tags = fields.List(fields.Str(validate=validate.Length(max=50)), validate=validate.Length(max=20))
+    image_url = fields.Url(allow_none=True, schemes={'http', 'https'}, validate=validate.Length(max=2048))
+    barcode = fields.Str(allow_none=True, validate=validate.Regexp(
+        r'^[0-9A-Za-z-]{1,64}$', error='Must be up to 64 letters, digits or dashes.'))
+    # Updates only: reject the write with 409 if the item changed since this version was read
+    expected_version = fields.Int(validate=validate.Range(min=1))
+    
+    @pre_load
+    def normalize_item(self, data, **kwargs):
+        # Trim text and treat blank quantity/priority like omitted ones so they get the defaults above
+        if isinstance(data, dict):
+            data = dict(data)
+            if 'name' in data:
+                data['name'] = strip_text(data['name'])
+            if 'category' in data:
+                data['category'] = normalize_category(data['category'])
+            if 'amount' in data:
+                data['amount'] = strip_text(data['amount']) or None
+            if data.get('quantity') is None or (data['quantity'] == 0 and not isinstance(data['quantity'], bool)):
+                data.pop('quantity', None)
+            if data.get('priority') is None or (isinstance(data['priority'], str) and not data['priority'].strip()):
+                data.pop('priority', None)
+        return data
+    
+    @validates_schema
+    def validate_recurrence(self, data, **kwargs):
+        if data.get('recurring') and not data.get('recur_interval_days'):
+            raise ValidationError('Missing data for required field.', 'recur_interval_days')
+
+# Item fields that are only written when present in the request
+OPTIONAL_ITEM_FIELDS = ('amount', 'assigned_to', 'recurring', 'recur_interval_days', 'due_date', 'image_url', 'barcode')
+
+# Curated quick-add items per user
+MAX_FAVORITES_PER_USER = 100
+
+class FavoriteItemSchema(ShoppingListItemSchema):
+    class Meta:
+        fields = ('name', 'quantity', 'category', 'priority')
+
+# Item fields copied by the duplicate action (and accepted as overrides)
+DUPLICATE_ITEM_FIELDS = ('name', 'quantity', 'amount', 'category', 'priority', 'notes')
+
+HEX_COLOR = validate.Regexp(r'^#[0-9A-Fa-f]{6}$', error='Must be a hex color like #RRGGBB.')
 
 class ShoppingListSchema(Schema):
-    name = fields.Str(missing='My Shopping List', validate=lambda x: 1 <= len(x) <= 255)
+    name = fields.Str(missing='My Shopping List', validate=NAME_LENGTH)
+    color = fields.Str(allow_none=True, validate=HEX_COLOR)
+    icon = fields.Str(allow_none=True, validate=validate.Regexp(
+        r'^[a-z0-9][a-z0-9-]{0,49}$', error='Must be a short slug of lowercase letters, digits and dashes.'))
+    
+    @pre_load
+    def normalize_list(self, data, **kwargs):
+        if isinstance(data, dict) and 'name' in data:
+            data = dict(data, name=strip_text(data['name']))
+        return data
+
+# Number of collaborators who accepted a list's invitation (expects the list aliased as sl)
+LIST_SHARE_COUNT = "(SELECT COUNT(*) FROM list_shares WHERE list_id = sl.id AND status = 'accepted')"
+
+# Sort keys accepted by GET /api/lists mapped to the columns they order by
+LIST_SORT_COLUMNS = {
+    'name': 'LOWER(name)',
+    'created_at': 'created_at',
+    'updated_at': 'updated_at',
+    'last_activity_at': 'last_activity_at'
+}
+
+# Latest change to the list or any of its live items; used by GET /api/lists
+LIST_LAST_ACTIVITY = "GREATEST(sl.updated_at, MAX(sli.updated_at))"
+
+# List fields that can be patched independently on update
+UPDATABLE_LIST_FIELDS = ('name', 'color', 'icon')
+
+class ListGroupSchema(Schema):
+    name = fields.Str(required=True, validate=lambda x: 1 <= len(x.strip()) <= 100)
+
+class ListGroupAssignmentSchema(Schema):
+    group_id = fields.Int(required=True, allow_none=True)
+
+class ListMergeSchema(Schema):
+    source_list_id = fields.Int(required=True)
+    delete_source = fields.Bool(missing=False)
+    dedupe = fields.Bool(missing=False)  # Sum quantities of items with the same name and category
+
+class CategoryMergeSchema(Schema):
+    from_category = fields.Str(required=True, data_key='from', validate=lambda x: x in ITEM_CATEGORIES)
+    to_category = fields.Str(required=True, data_key='to', validate=lambda x: x in ITEM_CATEGORIES)
+    
+    @pre_load
+    def normalize_categories(self, data, **kwargs):
+        if isinstance(data, dict):
+            data = {key: normalize_category(value) if key in ('from', 'to') else value for key, value in data.items()}
+        return data
+    
+    @validates_schema
+    def validate_distinct(self, data, **kwargs):
+        if data.get('from_category') == data.get('to_category'):
+            raise ValidationError('Must differ from "from".', 'to')
+
+class CategoryCompletionSchema(Schema):
+    category = fields.Str(required=True, validate=lambda x: x in ITEM_CATEGORIES)
+    completed = fields.Bool(missing=True)
+    
+    @pre_load
+    def normalize_completion_category(self, data, **kwargs):
+        if isinstance(data, dict) and 'category' in data:
+            data = dict(data, category=normalize_category(data['category']))
+        return data
+
+class CategoryColorsSchema(Schema):
+    # Replaces the whole mapping; categories left out lose their color
+    colors = fields.Dict(keys=fields.Str(validate=lambda x: x in ITEM_CATEGORIES),
+                         values=fields.Str(validate=HEX_COLOR), required=True)
+    
+    @pre_load
+    def normalize_colors(self, data, **kwargs):
+        if isinstance(data, dict) and isinstance(data.get('colors'), dict):
+            data = dict(data, colors={
+                normalize_category(category): color.lower() if isinstance(color, str) else color
+                for category, color in data['colors'].items()
+            })
+        return data
+
+# Upper bound on list ids per POST /api/lists/batch request
+MAX_LIST_BATCH_SIZE = 50
+
+class ListBatchSchema(Schema):
+    ids = fields.List(fields.Int(), required=True, validate=validate.Length(min=1, max=MAX_LIST_BATCH_SIZE))
+
+# Upper bound on items per POST /api/lists/<id>/items/bulk request
+MAX_ITEM_BULK_SIZE = 100
+
+class ItemBulkSchema(Schema):
+    items = fields.List(fields.Nested(ShoppingListItemSchema), required=True,
+                        validate=validate.Length(min=1, max=MAX_ITEM_BULK_SIZE))
+
+# Item fields a bulk update can change
+BULK_UPDATE_ITEM_FIELDS = ('quantity', 'category', 'priority', 'completed', 'assigned_to')
+
+class ItemBulkUpdateEntrySchema(Schema):
+    id = fields.Int(required=True)
+    # Validated per entry against ShoppingListItemSchema, limited to BULK_UPDATE_ITEM_FIELDS
+    updates = fields.Dict(required=True)
+
+class ItemBulkUpdateSchema(Schema):
+    items = fields.List(fields.Nested(ItemBulkUpdateEntrySchema), required=True,
+                        validate=validate.Length(min=1, max=MAX_ITEM_BULK_SIZE))
+
+# Format version of GET /api/users/me/export, checked by POST /api/lists/import
+EXPORT_VERSION = 1
+
+class ListImportSchema(Schema):
+    # The export bundle; only its lists and items sections are read
+    version = fields.Int(required=True, validate=validate.Equal(EXPORT_VERSION, error='Unsupported export version.'))
+    list_id = fields.Int()  # Which exported list to restore; optional when the bundle holds one
+    name = fields.Str(validate=NAME_LENGTH)  # Defaults to the exported name
+    lists = fields.List(fields.Dict(), required=True, validate=validate.Length(min=1))
+    items = fields.List(fields.Dict(), missing=list)
+    
+    class Meta:
+        unknown = EXCLUDE
+
+class ListImportItemSchema(ShoppingListItemSchema):
+    # Completion and timestamps are kept with ?preserve=true
+    completed_at = fields.DateTime(allow_none=True)
+    created_at = fields.DateTime(allow_none=True)
+    updated_at = fields.DateTime(allow_none=True)
+    
+    class Meta:
+        fields = ('name', 'quantity', 'amount', 'category', 'priority', 'notes', 'completed', 'recurring',
+                  'recur_interval_days', 'due_date', 'tags', 'image_url', 'barcode',
+                  'completed_at', 'created_at', 'updated_at')
+        unknown = EXCLUDE
+
+# Webhooks per user, to bound fan-out on busy lists
+MAX_WEBHOOKS_PER_USER = 10
+
+class WebhookSchema(Schema):
+    url = fields.Url(required=True, schemes={'http', 'https'}, validate=validate.Length(max=2048))
+    events = fields.List(fields.Str(validate=validate.OneOf(WEBHOOK_EVENTS)), required=True,
+                         validate=validate.Length(min=1))
+    enabled = fields.Bool(missing=True)
+    # Generated when omitted; only returned when the webhook is created
+    secret = fields.Str(validate=validate.Length(min=16, max=128))
+
+class AnnouncementSchema(Schema):
+    title = fields.Str(required=True, validate=NAME_LENGTH)
+    message = fields.Str(required=True, validate=validate.Length(min=1, max=1000))
+    # Only these users when given; otherwise every enabled user
+    user_ids = fields.List(fields.Int(), validate=validate.Length(min=1))
+    
+    @pre_load
+    def normalize_announcement(self, data, **kwargs):
+        if isinstance(data, dict):
+            data = {key: strip_text(value) if key in ('title', 'message') else value for key, value in data.items()}
+        return data
+
+# Longest a notification can be snoozed for
+MAX_SNOOZE_MINUTES = 30 * 24 * 60
+
+class NotificationSnoozeSchema(Schema):
+    # Exactly one of a duration or a point in time (UTC when no offset is given)
+    minutes = fields.Int(validate=validate.Range(min=1, max=MAX_SNOOZE_MINUTES))
+    until = fields.AwareDateTime(default_timezone=timezone.utc)
+    
+    @validates_schema
+    def validate_one_of(self, data, **kwargs):
+        if ('minutes' in data) == ('until' in data):
+            raise ValidationError('Provide either minutes or until.')
+
+class ListSharingSchema(Schema):
+    is_shared = fields.Bool(required=True)
+
+class ShareLinkSchema(Schema):
+    # Joins allowed through the link; unlimited when omitted (1 makes a single-use link)
+    max_uses = fields.Int(allow_none=True, validate=validate.Range(min=1))
+
+class ListInviteSchema(Schema):
+    username = fields.Str(required=True, validate=lambda x: len(x.strip()) >= 1)
+    permission = fields.Str(missing='read', validate=lambda x: x in ['read', 'write', 'admin'])  # admin: owner only
+
+# Marshmallow's default messages mapped to short rule names for clients
+VALIDATION_RULES = [
+    ('Missing data for required field', 'required'),
+    ('Unknown field', 'unknown'),
+    ('Field may not be null', 'required'),
+    ('Not a valid email', 'email'),
+    ('Not a valid', 'type'),
+    ('Invalid input type', 'type'),
+    ('Length must be', 'length'),
+    ('Shorter than', 'length'),
+    ('Longer than', 'length'),
+    ('Must be one of', 'oneof'),
+    ('Must be greater than', 'range'),
+    ('String does not match expected pattern', 'pattern'),
+    ('Must be a hex color', 'pattern'),
+    ('Must be a short slug', 'pattern'),
+    ('Must be less than', 'range'),
+]
+
+def _validation_rule(message):
+    for prefix, rule in VALIDATION_RULES:
+        if message.startswith(prefix):
+            return rule
+    return 'invalid'
+
+def _flatten_validation_messages(messages, prefix=''):
+    """Flatten marshmallow's nested error dict into (field, message) pairs"""
+    if isinstance(messages, dict):
+        for key, value in messages.items():
+            field = f'{prefix}.{key}' if prefix else str(key)
+            yield from _flatten_validation_messages(value, field)
+    elif isinstance(messages, list):
+        for message in messages:
+            yield from _flatten_validation_messages(message, prefix)
+    else:
+        yield prefix or '_schema', str(messages)
+
+def validation_error_response(error):
+    """Build a 400 response with field-level errors from a validation failure"""
+    if not isinstance(error, ValidationError):
+        return jsonify({'error': 'Validation error', 'errors': [
+            {'field': '_schema', 'rule': 'invalid', 'message': str(error)}
+        ]}), 400
+    
+    errors = [
+        {'field': field, 'rule': _validation_rule(message), 'message': message}
+        for field, message in _flatten_validation_messages(error.messages)
+    ]
+    return jsonify({'error': 'Validation error', 'errors': errors}), 400
+
+# Columns returned for shopping list items, including attribution usernames
+ITEM_COLUMNS = """
+    id, name, quantity, amount, category, priority, notes, completed, created_at, updated_at,
+    created_by, (SELECT username FROM users WHERE users.id = created_by) AS created_by_username,
+    completed_by, (SELECT username FROM users WHERE users.id = completed_by) AS completed_by_username, completed_at,
+    assigned_to, (SELECT username FROM users WHERE users.id = assigned_to) AS assigned_to_username,
+    recurring, recur_interval_days, due_date, recurred_from, image_url, barcode, version,
+    ARRAY(
+        SELECT t.name FROM shopping_list_item_tags it
+        JOIN item_tags t ON t.id = it.tag_id
+        WHERE it.item_id = shopping_list_items.id
+        ORDER BY t.name
+    ) AS tags
+"""
+
+class ListPermission(IntEnum):
+    """
+    What a user may do on a list; each level includes the ones below it
+    READ: view the list and its items
+    WRITE: add, edit, complete and delete items
+    ADMIN: rename or delete the list and manage read/write collaborators and the share link
+    OWNER: everything, including granting admin
+    A user can only grant, change or revoke shares below their own level
+    """
+    READ = 1
+    WRITE = 2
+    ADMIN = 3
+    OWNER = 4
+
+def can_grant_permission(granter_level, permission):
+    """Whether a user at granter_level may hand out (or take away) a share permission"""
+    return granter_level > ListPermission[permission.upper()]
+
+def get_list_permission(cur, list_id, user_id):
+    """Return the user's ListPermission on a list, or None without access"""
+    cur.execute("""
+        SELECT sl.owner_id = %s AS is_owner, ls.permission
+        FROM shopping_lists sl
+        LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+        WHERE sl.id = %s
+    """, (user_id, user_id, list_id))
+    row = cur.fetchone()
+    if not row:
+        return None
+    if row['is_owner']:
+        return ListPermission.OWNER
+    if row['permission']:
+        return ListPermission[row['permission'].upper()]
+    return None
+
+def has_list_permission(cur, list_id, user_id, required):
+    permission = get_list_permission(cur, list_id, user_id)
+    return permission is not None and permission >= required
+
+def is_list_member(cur, list_id, user_id):
+    """Check whether a user owns the list or has an accepted share on it"""
+    return has_list_permission(cur, list_id, user_id, ListPermission.READ)
+
+def list_access_denied(cur, list_id, user_id):
+    """
+    Response for a failed write/manage check: 403 when the user is on the list but lacks the
+    permission, 404 otherwise so outsiders can't tell which lists exist
+    """
+    if get_list_permission(cur, list_id, user_id) is not None:
+        return jsonify({'error': 'You do not have permission to do this on this list'}), 403
+    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+
+def can_write_list(cur, list_id, user_id):
+    """Check whether a user owns the list or has an accepted write/admin share on it"""
+    return has_list_permission(cur, list_id, user_id, ListPermission.WRITE)
+
+def can_manage_list(cur, list_id, user_id):
+    """Check whether a user owns the list or has an accepted admin share on it"""
+    return has_list_permission(cur, list_id, user_id, ListPermission.ADMIN)
+
+# Notification types clients know how to render
+NOTIFICATION_TYPES = (
+    'share_invitation', 'share_accepted', 'share_declined', 'share_removed',
+    'item_assigned', 'list_deleted', 'share_joined', 'announcement', 'digest', 'list_transferred'
+)
+NOTIFICATION_TITLE_MAX_LENGTH = 255
+NOTIFICATION_MESSAGE_MAX_LENGTH = 1000
+
+def create_notification(cur, user_id, notification_type, title, message, data=None):
+    """Insert a notification for a user within the caller's transaction"""
+    if notification_type not in NOTIFICATION_TYPES:
+        raise ValueError(f'Unknown notification type: {notification_type}')
+    # Clients read fields such as list_id straight off data, so it is always a JSON object
+    if data is not None and not isinstance(data, dict):
+        raise ValueError('Notification data must be a dict')
+    
+    # Messages quote user-provided names, so keep them bounded
+    cur.execute("""
+        INSERT INTO notifications (user_id, type, title, message, data)
+        VALUES (%s, %s, %s, %s, %s)
+    """, (user_id, notification_type, title[:NOTIFICATION_TITLE_MAX_LENGTH],
+          message[:NOTIFICATION_MESSAGE_MAX_LENGTH], psycopg2.extras.Json(data or {})))
+
+def notify_item_assigned(cur, list_id, item, assigner_id):
+    """Let the assignee know an item was assigned to them (skipped for self-assignment)"""
+    if not item['assigned_to'] or item['assigned_to'] == assigner_id:
+        return
+    
+    cur.execute("""
+        SELECT sl.name AS list_name, u.username AS assigner_username
+        FROM shopping_lists sl, users u
+        WHERE sl.id = %s AND u.id = %s
+    """, (list_id, assigner_id))
+    info = cur.fetchone()
+    
+    create_notification(
+        cur, item['assigned_to'], 'item_assigned', 'Item Assigned',
+        f'{info["assigner_username"]} assigned "{item["name"]}" in "{info["list_name"]}" to you',
+        {'list_id': list_id, 'item_id': item['id'], 'assigner_user_id': assigner_id}
+    )
+
+# Item history helpers
+ITEM_HISTORY_FIELDS = ('name', 'quantity', 'amount', 'category', 'priority', 'notes', 'completed', 'assigned_to',
+                       'recurring', 'recur_interval_days', 'due_date', 'image_url', 'barcode')
+
+def record_item_history(cur, list_id, item_id, user_id, change_type, before=None, after=None):
+    """Record an item change in item_history within the caller's transaction"""
+    changes = {}
+    for field in ITEM_HISTORY_FIELDS:
+        old_value = before.get(field) if before else None
+        new_value = after.get(field) if after else None
+        if old_value != new_value:
+            changes[field] = {
+                'old': old_value.isoformat() if hasattr(old_value, 'isoformat') else old_value,
+                'new': new_value.isoformat() if hasattr(new_value, 'isoformat') else new_value
+            }
+    
+    if change_type == 'updated' and not changes:
+        return
+    
+    cur.execute("""
+        INSERT INTO item_history (item_id, list_id, user_id, change_type, changes)
+        VALUES (%s, %s, %s, %s, %s)
+    """, (item_id, list_id, user_id, change_type, psycopg2.extras.Json(changes)))
+    
+    # Every item change passes through here, so this is where webhooks fire
+    queue_webhook_event(cur, list_id, f'item.{change_type}',
+                        {'item_id': item_id, 'user_id': user_id, 'changes': changes})
+
+def record_list_event(cur, list_id, user_id, event_type, data=None):
+    """Record a list-level change (e.g. a rename) for the activity feed"""
+    cur.execute("""
+        INSERT INTO list_events (list_id, user_id, event_type, data)
+        VALUES (%s, %s, %s, %s)
+    """, (list_id, user_id, event_type, psycopg2.extras.Json(data or {})))
+
+def queue_webhook_event(cur, list_id, event_type, data):
+    """
+    Queue an event for every enabled webhook subscribed to it whose owner can see the list.
+    Runs in the caller's transaction so nothing is sent for changes that roll back.
+    """
+    payload = {
+        'event': event_type,
+        'list_id': list_id,
+        'occurred_at': datetime.utcnow().isoformat() + 'Z',
+        'data': data
+    }
+    cur.execute("""
+        INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+        SELECT w.id, %s, %s
+        FROM webhooks w
+        JOIN shopping_lists sl ON sl.id = %s
+        WHERE w.enabled AND %s = ANY(w.events) AND (
+            w.user_id = sl.owner_id OR EXISTS (
+                SELECT 1 FROM list_shares ls
+                WHERE ls.list_id = sl.id AND ls.user_id = w.user_id AND ls.status = 'accepted'
+            )
+        )
+    """, (event_type, psycopg2.extras.Json(payload, dumps=lambda value: json.dumps(value, default=str)),
+          list_id, event_type))
+
+# Tag helpers
+def normalize_tags(tags):
+    """Trim, lowercase and de-duplicate tag names, dropping empty ones"""
+    return sorted({tag.strip().lower() for tag in tags if tag and tag.strip()})
+
+def set_item_tags(cur, item_id, user_id, tags):
+    """Replace an item's tags, creating any of the user's tags that don't exist yet"""
+    tags = normalize_tags(tags)
+    cur.execute("DELETE FROM shopping_list_item_tags WHERE item_id = %s", (item_id,))
+    
+    for tag in tags:
+        cur.execute("""
+            INSERT INTO item_tags (user_id, name)
+            VALUES (%s, %s)
+            ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+            RETURNING id
+        """, (user_id, tag))
+        tag_id = cur.fetchone()['id']
+        cur.execute("""
+            INSERT INTO shopping_list_item_tags (item_id, tag_id)
+            VALUES (%s, %s)
+            ON CONFLICT DO NOTHING
+        """, (item_id, tag_id))
+    
+    return tags
+
+def reassign_default_list(cur, list_id):
+    """Before deleting a list, point its owner's default at their most recently updated other list (or none)"""
+    cur.execute("""
+        UPDATE users u
+        SET default_list_id = (
+            SELECT id FROM shopping_lists
+            WHERE owner_id = u.id AND id <> %s
+            ORDER BY updated_at DESC, id DESC
+            LIMIT 1
+        )
+        WHERE u.default_list_id = %s
+    """, (list_id, list_id))
+
+def delete_list(cur, list_id, user_id):
+    """
+    Delete a list within the caller's transaction, telling its other members and webhooks first.
+    Returns the deleted list's id, name, owner_id and deleted_by.
+    """
+    cur.execute("""
+        SELECT sl.id, sl.name, sl.owner_id, u.username AS deleted_by
+        FROM shopping_lists sl, users u
+        WHERE sl.id = %s AND u.id = %s
+    """, (list_id, user_id))
+    list_data = cur.fetchone()
+    
+    # Let everyone else on the list know so their clients can drop it
+    cur.execute("""
+        SELECT user_id FROM list_shares
+        WHERE list_id = %s AND status = 'accepted'
+        UNION
+        SELECT %s
+    """, (list_id, list_data['owner_id']))
+    for member in cur.fetchall():
+        if member['user_id'] == user_id:
+            continue
+        create_notification(
+            cur, member['user_id'], 'list_deleted', 'List Deleted',
+            f'"{list_data["name"]}" was deleted by {list_data["deleted_by"]}',
+            {'list_id': list_id}
+        )
+    
+    # Queued before the delete, while the list's collaborators can still be resolved
+    queue_webhook_event(cur, list_id, 'list.deleted', {'user_id': user_id, 'name': list_data['name']})
+    
+    reassign_default_list(cur, list_id)
+    
+    # Delete the list (CASCADE will delete items automatically)
+    cur.execute("DELETE FROM shopping_lists WHERE id = %s", (list_id,))
+    return list_data
+
+# Bulk updates and clear-completed can be undone by their author (or a list admin) for this long
+UNDO_WINDOW = timedelta(minutes=int(os.getenv('UNDO_WINDOW_MINUTES', 5)))
+
+def record_item_operation(cur, list_id, user_id, operation, snapshot):
+    """Log a bulk change with what is needed to revert it; returns the operation id"""
+    cur.execute("""
+        INSERT INTO item_operations (list_id, user_id, operation, snapshot)
+        VALUES (%s, %s, %s, %s)
+        RETURNING id
+    """, (list_id, user_id, operation, psycopg2.extras.Json(snapshot, dumps=app.json.dumps)))
+    return cur.fetchone()['id']
+
+def list_item_limit(cur, list_id):
+    """MAX_ITEMS_PER_LIST, or GUEST_MAX_ITEMS_PER_LIST for lists owned by a guest"""
+    cur.execute("""
+        SELECT u.is_guest FROM shopping_lists sl JOIN users u ON u.id = sl.owner_id WHERE sl.id = %s
+    """, (list_id,))
+    owner = cur.fetchone()
+    return GUEST_MAX_ITEMS_PER_LIST if owner and owner['is_guest'] else MAX_ITEMS_PER_LIST
+
+def item_quota_exceeded(cur, list_id, adding=1):
+    """Whether adding items would take the list past its item limit"""
+    cur.execute(
+        "SELECT COUNT(*) AS count FROM shopping_list_items WHERE list_id = %s AND deleted_at IS NULL",
+        (list_id,)
+    )
+    return cur.fetchone()['count'] + adding > list_item_limit(cur, list_id)
+
+def item_quota_response(cur, list_id):
+    """403 returned when a list is at its item limit"""
+    return jsonify({'error': f'A shopping list can hold at most {list_item_limit(cur, list_id)} items'}), 403
+
+def insert_list_item(cur, list_id, user_id, data):
+    """Insert a validated item with its tags, history and grocery memory; returns the new item"""
+    cur.execute(f"""
+        INSERT INTO shopping_list_items (list_id, name, quantity, amount, category, priority, notes, created_by,
+                                         assigned_to, recurring, recur_interval_days, due_date, image_url,
+                                         barcode)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING {ITEM_COLUMNS}
+    """, (list_id, data['name'], data['quantity'], data.get('amount'), data['category'], data['priority'], data['notes'], user_id,
+          data.get('assigned_to'), data.get('recurring', False), data.get('recur_interval_days'), data.get('due_date'),
+          data.get('image_url'), data.get('barcode')))
+    
+    item = dict(cur.fetchone())
+    if data.get('tags'):
+        item['tags'] = set_item_tags(cur, item['id'], user_id, data['tags'])
+    record_item_history(cur, list_id, item['id'], user_id, 'created', after=item)
+    notify_item_assigned(cur, list_id, item, user_id)
+    
+    # Update grocery memory
+    cur.execute("""
+        INSERT INTO grocery_memory (user_id, name, category, priority, usage_count, last_used)
+        VALUES (%s, %s, %s, %s, 1, CURRENT_TIMESTAMP)
+        ON CONFLICT (user_id, name) 
+        DO UPDATE SET 
+            category = EXCLUDED.category,
+            priority = EXCLUDED.priority,
+            usage_count = grocery_memory.usage_count + 1,
+            last_used = CURRENT_TIMESTAMP
+    """, (user_id, data['name'], data['category'], data['priority']))
+    
+    return item
+
+def schedule_recurrence(cur, list_id, item, user_id):
+    """
+    Queue the next occurrence of a recurring item that was just completed.
+    Re-uses a pending copy from an earlier completion so repeated completes don't duplicate it.
+    """
+    if not item['recurring'] or not item['recur_interval_days']:
+        return
+    
+    cur.execute("""
+        UPDATE shopping_list_items
+        SET due_date = CURRENT_DATE + %s
+        WHERE recurred_from = %s AND completed = FALSE AND deleted_at IS NULL
+        RETURNING id
+    """, (item['recur_interval_days'], item['id']))
+    if cur.fetchone():
+        return
+    
+    cur.execute(f"""
+        INSERT INTO shopping_list_items (list_id, name, quantity, amount, category, priority, notes, created_by,
+                                         assigned_to, recurring, recur_interval_days, due_date, recurred_from)
+        SELECT list_id, name, quantity, amount, category, priority, notes, %s,
+               assigned_to, recurring, recur_interval_days, CURRENT_DATE + recur_interval_days, id
+        FROM shopping_list_items
+        WHERE id = %s
+        RETURNING {ITEM_COLUMNS}
+    """, (user_id, item['id']))
+    next_item = cur.fetchone()
+    record_item_history(cur, list_id, next_item['id'], user_id, 'created', after=next_item)
+
+def parse_pagination(default_limit=None, max_limit=100):
+    """
+    Read limit/offset query params. Without a limit the full result is returned
+    unless a default_limit is given. Raises ValueError with a client-facing message.
+    """
+    limit = request.args.get('limit', default_limit)
+    offset = request.args.get('offset', 0)
+    
+    try:
+        limit = int(limit) if limit is not None else None
+        offset = int(offset)
+    except (TypeError, ValueError):
+        raise ValueError('limit and offset must be integers')
+    
+    if limit is not None and not 1 <= limit <= max_limit:
+        raise ValueError(f'limit must be between 1 and {max_limit}')
+    if offset < 0:
+        raise ValueError('offset must not be negative')
+    
+    return limit, offset
+
+def parse_sort(columns, default):
+    """
+    Map the sort query param to its ORDER BY expression in columns. ORDER BY can't be
+    parameterized, so only these allowlisted expressions are ever interpolated into SQL.
+    Raises ValueError with a client-facing message.
+    """
+    sort = request.args.get('sort', default)
+    if sort not in columns:
+        raise ValueError(f"sort must be one of: {', '.join(columns)}")
+    return columns[sort]
+
+def parse_order(default='desc'):
+    """Read the order query param as ASC or DESC; raises ValueError with a client-facing message"""
+    order = request.args.get('order', default).lower()
+    if order not in ('asc', 'desc'):
+        raise ValueError('order must be asc or desc')
+    return order.upper()
+
+# Localized timestamps
+def requested_timezone(cur, user_id):
+    """
+    Timezone to localize a response in: the ?tz= query param, else the user's stored timezone, else None.
+    Raises ValueError with a client-facing message for an unknown ?tz=.
+    """
+    name = request.args.get('tz')
+    if not name:
+        cur.execute("SELECT timezone FROM users WHERE id = %s", (user_id,))
+        row = cur.fetchone()
+        name = row['timezone'] if row else None
+    return parse_timezone(name) if name else None
+
+def localize_timestamp(value, tz):
+    """Render a stored (UTC) timestamp in tz as ISO 8601 with its offset"""
+    return value.replace(tzinfo=timezone.utc).astimezone(tz).isoformat() if value else None
+
+# Idempotency helpers
+def claim_idempotency_key(cur, user_id):
+    """
+    Claim the request's Idempotency-Key within the caller's transaction.
+    Returns (key, replay): key is None when no header was sent; replay is a response
+    to return as-is when the key was already used. A concurrent request with the same
+    key waits on the claimed row until the first transaction finishes.
+    """
+    key = request.headers.get('Idempotency-Key', '').strip()
+    if not key:
+        return None, None
+    if len(key) > 255:
+        return None, (jsonify({'error': 'Idempotency-Key must be at most 255 characters'}), 400)
+    
+    cur.execute(
+        "DELETE FROM idempotency_keys WHERE user_id = %s AND expires_at < CURRENT_TIMESTAMP",
+        (user_id,)
+    )
+    cur.execute("""
+        INSERT INTO idempotency_keys (user_id, idempotency_key, request_path, expires_at)
+        VALUES (%s, %s, %s, %s)
+        ON CONFLICT (user_id, idempotency_key) DO NOTHING
+        RETURNING id
+    """, (user_id, key, request.path, datetime.utcnow() + IDEMPOTENCY_KEY_TTL))
+    if cur.fetchone():
+        return key, None
+    
+    cur.execute("""
+        SELECT request_path, response_status, response_body
+        FROM idempotency_keys
+        WHERE user_id = %s AND idempotency_key = %s
+    """, (user_id, key))
+    stored = cur.fetchone()
+    
+    if stored['request_path'] != request.path:
+        return key, (jsonify({'error': 'Idempotency-Key was already used for a different request'}), 422)
+    if stored['response_body'] is None:
+        return key, (jsonify({'error': 'A request with this Idempotency-Key is still being processed'}), 409)
+    
+    response = jsonify(stored['response_body'])
+    response.headers['Idempotent-Replayed'] = 'true'
+    return key, (response, stored['response_status'])
+
+def save_idempotent_response(cur, user_id, key, body, status):
+    """Store the response for a claimed key so retries get the same result"""
+    cur.execute("""
+        UPDATE idempotency_keys SET response_status = %s, response_body = %s
+        WHERE user_id = %s AND idempotency_key = %s
+    """, (status, psycopg2.extras.Json(body, dumps=app.json.dumps), user_id, key))
+
+def conditional_json(payload):
+    """JSON response tagged with an ETag of its body; 304 Not Modified when If-None-Match matches"""
+    response = jsonify(payload)
+    response.add_etag()
+    return response.make_conditional(request)
+
+def frontend_link(path):
+    """Build an absolute link into the frontend"""
+    frontend_url = os.getenv('FRONTEND_URL', 'http://localhost:3000/')
+    if not frontend_url.endswith('/'):
+        frontend_url += '/'
+    return f"{frontend_url}{path}"
+
+# Email verification helpers
+def hash_token(token):
+    """Tokens sent by email are only stored as SHA-256 digests"""
+    return hashlib.sha256(token.encode('utf-8')).hexdigest()
+
+def create_email_verification_token(cur, user_id):
+    """Replace any outstanding verification token for the user and return the new raw token"""
+    token = secrets.token_urlsafe(32)
+    cur.execute("DELETE FROM email_verification_tokens WHERE user_id = %s", (user_id,))
+    cur.execute("""
+        INSERT INTO email_verification_tokens (user_id, token_hash, expires_at)
+        VALUES (%s, %s, %s)
+    """, (user_id, hash_token(token), datetime.utcnow() + EMAIL_VERIFICATION_TTL))
+    return token
+
+def send_verification_email(email, username, token):
+    """Email the verification link; failures are logged so registration still succeeds"""
+    try:
+        mailer.send(
+            email,
+            'Verify your email address',
+            f"Hi {username},\n\n"
+            f"Please confirm your email address by opening this link:\n"
+            f"{frontend_link(f'verify-email?token={token}')}\n\n"
+            f"The link expires in {int(EMAIL_VERIFICATION_TTL.total_seconds() // 3600)} hours."
+        )
+    except Exception as e:
+        print(f"Send verification email error: {e}")
+
+def sharing_blocked_by_verification(cur, user_id):
+    """Whether sharing is gated on a verified email that this user doesn't have yet"""
+    if not REQUIRE_VERIFIED_EMAIL_FOR_SHARING:
+        return False
+    cur.execute("SELECT email_verified FROM users WHERE id = %s", (user_id,))
+    user = cur.fetchone()
+    return not user or not user['email_verified']
+
+# Session helpers
+def issue_access_token(user_id, expires_delta=None):
+    """Create an access token and record it as a session the user can later revoke"""
+    access_token = create_access_token(identity=str(user_id), expires_delta=expires_delta)
+    claims = decode_token(access_token)
+    
+    with get_db_connection() as conn:
+        with conn.cursor() as cur:
+            cur.execute("""
+                INSERT INTO user_sessions (user_id, jti, ip_address, user_agent, expires_at)
+                VALUES (%s, %s, %s, %s, %s)
+            """, (
+                user_id,
+                claims['jti'],
+                request.environ.get('REMOTE_ADDR'),
+                request.headers.get('User-Agent'),
+                datetime.utcfromtimestamp(claims['exp'])
+            ))
+            conn.commit()
+    
+    return access_token
+
+def token_response(payload, access_token, use_cookie=None, status=200):
+    """
+    Return the access token in the JSON body (default) or, in cookie mode,
+    only as an HttpOnly cookie so page scripts never see it
+    """
+    if use_cookie is None:
+        use_cookie = AUTH_COOKIE_DEFAULT
+    
+    if not use_cookie:
+        return jsonify({**payload, 'token': access_token}), status
+    
+    response = jsonify(payload)
+    set_access_cookies(response, access_token)
+    return response, status
+
+def is_user_disabled(user_id):
+    """Whether an administrator has disabled the account"""
+    with get_db_connection() as conn:
+        with conn.cursor() as cur:
+            cur.execute("SELECT disabled_at FROM users WHERE id = %s", (user_id,))
+            user = cur.fetchone()
+    return user is not None and user[0] is not None
+
+def admin_required(fn):
+    """Restrict a route to administrators; goes below @jwt_required()"""
+    @wraps(fn)
+    def wrapper(*args, **kwargs):
+        with get_db_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute(
+                    "SELECT is_admin FROM users WHERE id = %s AND disabled_at IS NULL",
+                    (int(get_jwt_identity()),)
+                )
+                user = cur.fetchone()
+        if not user or not user[0]:
+            return jsonify({'error': 'Administrator access required'}), 403
+        return fn(*args, **kwargs)
+    return wrapper
+
+# Sessions' last_seen_at is only refreshed this often, so most requests stay read-only
+SESSION_ACTIVITY_INTERVAL = timedelta(minutes=5)
+
+@jwt.token_in_blocklist_loader
+def is_session_revoked(jwt_header, jwt_payload):
+    """Reject tokens whose session was revoked; tokens issued before sessions existed stay valid"""
+    with get_db_connection() as conn:
+        with conn.cursor() as cur:
+            cur.execute("""
+                SELECT revoked_at, last_seen_at < CURRENT_TIMESTAMP - %s AS stale
+                FROM user_sessions WHERE jti = %s
+            """, (SESSION_ACTIVITY_INTERVAL, jwt_payload['jti']))
+            session = cur.fetchone()
+            
+            if session and session[0] is None and session[1]:
+                cur.execute("""
+                    UPDATE user_sessions SET last_seen_at = CURRENT_TIMESTAMP
+                    WHERE jti = %s AND last_seen_at < CURRENT_TIMESTAMP - %s
+                """, (jwt_payload['jti'], SESSION_ACTIVITY_INTERVAL))
+            conn.commit()
+    
+    return session is not None and session[0] is not None
+
+@app.after_request
+def refresh_expiring_token(response):
+    """Renew tokens that are close to expiring so active users aren't logged out abruptly"""
+    if not TOKEN_REFRESH_THRESHOLD or response.status_code >= 400:
+        return response
+    
+    try:
+        claims = get_jwt()
+    except RuntimeError:
+        # No token was verified for this request
+        return response
+    
+    if not claims:
+        return response
+    
+    # Renewed tokens keep their original lifetime, so a short-lived guest token doesn't become a
+    # full-length one; tokens shorter than the threshold renew once half their lifetime has passed
+    lifetime = timedelta(seconds=claims['exp'] - claims['iat'])
+    if datetime.utcfromtimestamp(claims['exp']) - datetime.utcnow() > min(TOKEN_REFRESH_THRESHOLD, lifetime / 2):
+        return response
+    
+    try:
+        access_token = create_access_token(identity=claims['sub'], expires_delta=lifetime)
+        new_claims = decode_token(access_token)
+        
+        # Keep the same session row so the session list doesn't grow with each renewal
+        with get_db_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute("""
+                    UPDATE user_sessions SET jti = %s, expires_at = %s
+                    WHERE jti = %s
+                """, (new_claims['jti'], datetime.utcfromtimestamp(new_claims['exp']), claims['jti']))
+                conn.commit()
+        
+        response.headers['X-Refreshed-Token'] = access_token
+        if request.cookies.get(app.config['JWT_ACCESS_COOKIE_NAME']):
+            set_access_cookies(response, access_token)
+    except Exception as e:
+        print(f"Token refresh error: {e}")
+    
+    return response
+
+@app.before_request
+def limit_request_size():
+    """Reject oversized bodies with 413 before a handler parses them"""
+    if request.endpoint == 'upload_item_image':
+        return None
+    
+    size = request.content_length
+    if size is None and request.headers.get('Transfer-Encoding', '').lower() == 'chunked':
+        # No declared length: read it now (bounded by MAX_CONTENT_LENGTH) so handlers get the cached body
+        size = len(request.get_data())
+    if size is not None and size > MAX_REQUEST_BYTES:
+        return request_too_large(None)
+    return None
 
 # Error handlers
+@app.errorhandler(413)
+def request_too_large(e):
+    # Image uploads skip MAX_REQUEST_BYTES and are only stopped by the larger MAX_CONTENT_LENGTH
+    limit = app.config['MAX_CONTENT_LENGTH'] if request.endpoint == 'upload_item_image' else MAX_REQUEST_BYTES
+    return jsonify({'error': f'Request body must be at most {limit // 1024} KB'}), 413
+
 @app.errorhandler(ValidationError)
 def handle_validation_error(e):
-    return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+    return validation_error_response(e)
 
 @app.errorhandler(psycopg2.Error)
 def handle_db_error(e):
@@ -100,9 +1348,62 @@ def health_check():
     return jsonify({
         'status': 'healthy',
         'timestamp': datetime.utcnow().isoformat(),
-        'version': '1.0.0'
+        'version': '1.0.0',
+        'database_pool': db_pool_stats()
+    })
+
+# Server clock, so clients can estimate their skew when ordering timestamps
+@app.route('/api/time', methods=['GET'])
+def get_server_time():
+    now = datetime.now(timezone.utc)
+    return jsonify({
+        'time': now.isoformat(timespec='milliseconds').replace('+00:00', 'Z'),
+        'epoch_ms': int(now.timestamp() * 1000)
     })
 
+# Sample items put on a new user's first list
+SAMPLE_ITEMS = [
+    ('Milk', 1, 'dairy', 'medium', 'Organic preferred'),
+    ('Bananas', 6, 'produce', 'low', 'Not too ripe'),
+    ('Chicken Breast', 2, 'meat', 'high', '1 lb package'),
+    ('Bread', 1, 'bakery', 'medium', 'Whole wheat'),
+    ('Greek Yogurt', 2, 'dairy', 'low', 'Vanilla flavor')
+]
+
+def sample_priority(priority):
+    """Map a sample item's low/medium/high onto ITEM_PRIORITIES, which a deployment may have replaced"""
+    if priority in ITEM_PRIORITIES:
+        return priority
+    return {'low': ITEM_PRIORITIES[0], 'high': ITEM_PRIORITIES[-1]}.get(priority, DEFAULT_ITEM_PRIORITY)
+
+def create_starter_list(cur, user_id):
+    """Create a new user's default list with the sample items, also seeding their grocery memory"""
+    cur.execute(
+        "INSERT INTO shopping_lists (name, owner_id) VALUES (%s, %s) RETURNING id",
+        ('My Shopping List', user_id)
+    )
+    list_id = cur.fetchone()['id']
+    
+    for item_name, quantity, category, priority, notes in SAMPLE_ITEMS:
+        priority = sample_priority(priority)
+        cur.execute("""
+            INSERT INTO shopping_list_items (list_id, name, quantity, category, priority, notes, created_by)
+            VALUES (%s, %s, %s, %s, %s, %s, %s)
+        """, (list_id, item_name, quantity, category, priority, notes, user_id))
+        
+        cur.execute("""
+            INSERT INTO grocery_memory (user_id, name, category, priority, usage_count, last_used)
+            VALUES (%s, %s, %s, %s, 1, CURRENT_TIMESTAMP)
+            ON CONFLICT (user_id, name) 
+            DO UPDATE SET 
+                category = EXCLUDED.category,
+                priority = EXCLUDED.priority,
+                usage_count = grocery_memory.usage_count + 1,
+                last_used = CURRENT_TIMESTAMP
+        """, (user_id, item_name, category, priority))
+    
+    return list_id
+
 # Authentication routes
 @app.route('/api/auth/register', methods=['POST'])
 def register():
@@ -115,81 +1416,161 @@ def register():
         password = data['password']
         
         # Hash password
-        password_hash = bcrypt.hashpw(password.encode('utf-8'), bcrypt.gensalt()).decode('utf-8')
+        password_hash = hash_password(password)
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 # Check if user exists
-                cur.execute("SELECT id FROM users WHERE email = %s OR username = %s", (email, username))
+                cur.execute(
+                    "SELECT id FROM users WHERE LOWER(email) = LOWER(%s) OR LOWER(username) = LOWER(%s)",
+                    (email, username)
+                )
                 if cur.fetchone():
                     return jsonify({'error': 'User already exists with this email or username'}), 409
                 
                 # Create user
                 cur.execute(
-                    "INSERT INTO users (username, email, password_hash) VALUES (%s, %s, %s) RETURNING id, username, email, created_at",
-                    (username, email, password_hash)
+                    "INSERT INTO users (username, email, password_hash, is_admin) VALUES (%s, %s, %s, %s) RETURNING id, username, email, created_at",
+                    (username, email, password_hash, email.lower() in ADMIN_EMAILS)
                 )
                 user = cur.fetchone()
                 
-                # Create default shopping list
-                cur.execute(
-                    "INSERT INTO shopping_lists (name, owner_id) VALUES (%s, %s) RETURNING id",
-                    ('My Shopping List', user['id'])
-                )
-                list_result = cur.fetchone()
-                list_id = list_result['id']
-                
-                # Add sample items to the list
-                sample_items = [
-                    ('Milk', 1, 'dairy', 'medium', 'Organic preferred'),
-                    ('Bananas', 6, 'produce', 'low', 'Not too ripe'),
-                    ('Chicken Breast', 2, 'meat', 'high', '1 lb package'),
-                    ('Bread', 1, 'bakery', 'medium', 'Whole wheat'),
-                    ('Greek Yogurt', 2, 'dairy', 'low', 'Vanilla flavor')
-                ]
-                
-                for item_name, quantity, category, priority, notes in sample_items:
-                    # Add to shopping list
-                    cur.execute("""
-                        INSERT INTO shopping_list_items (list_id, name, quantity, category, priority, notes)
-                        VALUES (%s, %s, %s, %s, %s, %s)
-                    """, (list_id, item_name, quantity, category, priority, notes))
-                    
-                    # Add to grocery memory
-                    cur.execute("""
-                        INSERT INTO grocery_memory (user_id, name, category, priority, usage_count, last_used)
-                        VALUES (%s, %s, %s, %s, 1, CURRENT_TIMESTAMP)
-                        ON CONFLICT (user_id, name) 
-                        DO UPDATE SET 
-                            category = EXCLUDED.category,
-                            priority = EXCLUDED.priority,
-                            usage_count = grocery_memory.usage_count + 1,
-                            last_used = CURRENT_TIMESTAMP
-                    """, (user['id'], item_name, category, priority))
+                create_starter_list(cur, user['id'])
                 
+                verification_token = create_email_verification_token(cur, user['id'])
                 
                 conn.commit()
                 
+                send_verification_email(user['email'], user['username'], verification_token)
+                
                 # Create access token
-                access_token = create_access_token(identity=str(user['id']))
+                access_token = issue_access_token(user['id'])
                 
-                return jsonify({
+                return token_response({
                     'message': 'User registered successfully',
                     'user': {
                         'id': user['id'],
                         'username': user['username'],
                         'email': user['email'],
+                        'email_verified': False,
                         'created_at': user['created_at'].isoformat()
-                    },
-                    'token': access_token
-                }), 201
+                    }
+                }, access_token, data['use_cookie'], 201)
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
     except Exception as e:
         print(f"Registration error: {e}")
         return jsonify({'error': 'Failed to register user'}), 500
 
+@app.route('/api/auth/guest', methods=['POST'])
+def create_guest():
+    if not GUEST_MODE_ENABLED:
+        return jsonify({'error': 'Guest mode is disabled'}), 404
+    
+    try:
+        use_cookie = (request.get_json(silent=True) or {}).get('use_cookie')
+        handle = f'guest-{secrets.token_hex(6)}'
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT COUNT(*) AS created FROM user_sessions s
+                    JOIN users u ON u.id = s.user_id
+                    WHERE u.is_guest AND s.ip_address = %s AND s.created_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'
+                """, (request.environ.get('REMOTE_ADDR'),))
+                if cur.fetchone()['created'] >= GUESTS_PER_HOUR:
+                    return jsonify({'error': 'Too many guest accounts created, try again later'}), 429
+                
+                # Guests have no password, so they can only sign in with the token issued here
+                cur.execute("""
+                    INSERT INTO users (username, email, password_hash, is_guest)
+                    VALUES (%s, %s, '', TRUE)
+                    RETURNING id, username, created_at
+                """, (handle, f'{handle}@guest.invalid'))
+                user = cur.fetchone()
+                
+                create_starter_list(cur, user['id'])
+                
+                conn.commit()
+        
+        access_token = issue_access_token(user['id'], expires_delta=GUEST_TOKEN_TTL)
+        
+        return token_response({
+            'message': 'Guest account created',
+            'user': {
+                'id': user['id'],
+                'username': user['username'],
+                'is_guest': True,
+                'created_at': user['created_at'].isoformat()
+            },
+            'expires_in': int(GUEST_TOKEN_TTL.total_seconds())
+        }, access_token, use_cookie, 201)
+        
+    except Exception as e:
+        print(f"Create guest error: {e}")
+        return jsonify({'error': 'Failed to create guest account'}), 500
+
+@app.route('/api/auth/guest/upgrade', methods=['POST'])
+@jwt_required()
+def upgrade_guest():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = UserRegistrationSchema()
+        data = schema.load(request.json or {})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("SELECT is_guest FROM users WHERE id = %s FOR UPDATE", (user_id,))
+                user = cur.fetchone()
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                if not user['is_guest']:
+                    return jsonify({'error': 'Only guest accounts can be upgraded'}), 409
+                
+                cur.execute(
+                    "SELECT id FROM users WHERE (LOWER(email) = LOWER(%s) OR LOWER(username) = LOWER(%s)) AND id != %s",
+                    (data['email'], data['username'], user_id)
+                )
+                if cur.fetchone():
+                    return jsonify({'error': 'User already exists with this email or username'}), 409
+                
+                cur.execute("""
+                    UPDATE users
+                    SET username = %s, email = %s, password_hash = %s, is_guest = FALSE,
+                        is_admin = %s, updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s
+                    RETURNING id, username, email, created_at
+                """, (data['username'], data['email'], hash_password(data['password']),
+                      data['email'] in ADMIN_EMAILS, user_id))
+                user = cur.fetchone()
+                
+                verification_token = create_email_verification_token(cur, user_id)
+                
+                conn.commit()
+        
+        send_verification_email(user['email'], user['username'], verification_token)
+        
+        # The short-lived guest token is replaced by a regular one
+        access_token = issue_access_token(user_id)
+        
+        return token_response({
+            'message': 'Guest account upgraded',
+            'user': {
+                'id': user['id'],
+                'username': user['username'],
+                'email': user['email'],
+                'email_verified': False,
+                'created_at': user['created_at'].isoformat()
+            }
+        }, access_token, data['use_cookie'])
+        
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Upgrade guest error: {e}")
+        return jsonify({'error': 'Failed to upgrade guest account'}), 500
+
 @app.route('/api/auth/login', methods=['POST'])
 def login():
     try:
@@ -206,39 +1587,80 @@ def login():
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 if is_email:
                     cur.execute(
-                        "SELECT id, username, email, password_hash FROM users WHERE email = %s",
+                        "SELECT id, username, email, password_hash, disabled_at FROM users WHERE LOWER(email) = LOWER(%s)",
                         (login,)
                     )
                 else:
                     cur.execute(
-                        "SELECT id, username, email, password_hash FROM users WHERE username = %s",
+                        "SELECT id, username, email, password_hash, disabled_at FROM users WHERE LOWER(username) = LOWER(%s)",
                         (login,)
                     )
                 
                 user = cur.fetchone()
                 
-                if not user or not bcrypt.checkpw(password.encode('utf-8'), user['password_hash'].encode('utf-8')):
+                if not user or not check_password(password, user['password_hash']):
                     return jsonify({'error': 'Invalid login or password'}), 401
+                if user['disabled_at']:
+                    return jsonify({'error': 'This account has been disabled'}), 403
+                
+                # Transparently upgrade hashes created with a weaker cost
+                if password_needs_rehash(user['password_hash']):
+                    cur.execute(
+                        "UPDATE users SET password_hash = %s WHERE id = %s",
+                        (hash_password(password), user['id'])
+                    )
+                    conn.commit()
                 
                 # Create access token
-                access_token = create_access_token(identity=str(user['id']))
+                access_token = issue_access_token(user['id'])
                 
-                return jsonify({
+                return token_response({
                     'message': 'Login successful',
                     'user': {
                         'id': user['id'],
                         'username': user['username'],
                         'email': user['email']
-                    },
-                    'token': access_token
-                })
+                    }
+                }, access_token, data['use_cookie'])
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
     except Exception as e:
         print(f"Login error: {e}")
         return jsonify({'error': 'Failed to login'}), 500
 
+@app.route('/api/auth/jwks.json', methods=['GET'])
+def get_jwks():
+    """Publish the token verification key so other services can validate RS256 tokens"""
+    if JWT_ALGORITHM != 'RS256':
+        return jsonify({'keys': []})
+    
+    public_key = serialization.load_pem_public_key(app.config['JWT_PUBLIC_KEY'].encode('utf-8'))
+    jwk = json.loads(RSAAlgorithm.to_jwk(public_key))
+    jwk.update({'use': 'sig', 'alg': 'RS256'})
+    
+    return jsonify({'keys': [jwk]})
+
+@app.route('/api/auth/logout', methods=['POST'])
+@jwt_required()
+def logout():
+    try:
+        with get_db_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute(
+                    "UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE jti = %s",
+                    (get_jwt()['jti'],)
+                )
+                conn.commit()
+        
+        response = jsonify({'message': 'Logged out successfully'})
+        unset_jwt_cookies(response)
+        return response, 200
+        
+    except Exception as e:
+        print(f"Logout error: {e}")
+        return jsonify({'error': 'Failed to logout'}), 500
+
 @app.route('/api/auth/me', methods=['GET'])
 @jwt_required()
 def get_current_user():
@@ -247,10 +1669,10 @@ def get_current_user():
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                cur.execute(
-                    "SELECT id, username, email, created_at FROM users WHERE id = %s",
-                    (user_id,)
-                )
+                cur.execute("""
+                    SELECT id, username, email, email_verified, is_admin, is_guest, timezone, locale, digest_enabled, created_at
+                    FROM users WHERE id = %s
+                """, (user_id,))
                 user = cur.fetchone()
                 
                 if not user:
@@ -261,6 +1683,12 @@ def get_current_user():
                         'id': user['id'],
                         'username': user['username'],
                         'email': user['email'],
+                        'email_verified': user['email_verified'],
+                        'is_admin': user['is_admin'],
+                        'is_guest': user['is_guest'],
+                        'timezone': user['timezone'],
+                        'locale': user['locale'],
+                        'digest_enabled': user['digest_enabled'],
                         'created_at': user['created_at'].isoformat()
                     }
                 })
@@ -269,501 +1697,2868 @@ def get_current_user():
         print(f"Get user error: {e}")
         return jsonify({'error': 'Failed to get user info'}), 500
 
-# Grocery memory routes
-@app.route('/api/groceries/memory', methods=['GET'])
+@app.route('/api/auth/me', methods=['PUT'])
 @jwt_required()
-def get_grocery_memory():
+def update_current_user():
     try:
         user_id = int(get_jwt_identity())
-        search = request.args.get('search', '')
-        limit = int(request.args.get('limit', 10))
+        schema = UserPreferencesSchema()
+        # Partial load: only the fields that were sent are changed; null clears one
+        data = schema.load(request.json, partial=True)
+        
+        if not data:
+            return jsonify({'error': 'No fields to update'}), 400
+        
+        set_clause = ', '.join(f'{field} = %s' for field in data)
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                if search:
-                    cur.execute("""
-                        SELECT name, category, priority, usage_count, last_used
-                        FROM grocery_memory 
-                        WHERE user_id = %s AND LOWER(name) LIKE LOWER(%s)
-                        ORDER BY usage_count DESC, last_used DESC 
-                        LIMIT %s
-                    """, (user_id, f'%{search}%', limit))
-                else:
-                    cur.execute("""
-                        SELECT name, category, priority, usage_count, last_used
-                        FROM grocery_memory 
-                        WHERE user_id = %s
-                        ORDER BY usage_count DESC, last_used DESC 
-                        LIMIT %s
-                    """, (user_id, limit))
+                cur.execute(f"""
+                    UPDATE users
+                    SET {set_clause}, updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s
+                    RETURNING id, username, email, email_verified, is_admin, timezone, locale, digest_enabled, created_at
+                """, (*data.values(), user_id))
                 
-                groceries = cur.fetchall()
+                user = cur.fetchone()
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Profile updated',
+                    'user': dict(user)
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Update user error: {e}")
+        return jsonify({'error': 'Failed to update user info'}), 500
+
+@app.route('/api/auth/me', methods=['DELETE'])
+@jwt_required()
+def delete_current_user():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = AccountDeletionSchema()
+        data = schema.load(request.json or {})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("SELECT username, password_hash FROM users WHERE id = %s FOR UPDATE", (user_id,))
+                user = cur.fetchone()
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                
+                if user['password_hash'] and not check_password(data.get('password', ''), user['password_hash']):
+                    return jsonify({'error': 'Password is incorrect'}), 403
+                
+                # Owned lists other people use; the longest-standing admin collaborator takes each one over
+                cur.execute("""
+                    SELECT sl.id, sl.name, heir.user_id AS heir_id, heir.username AS heir_username
+                    FROM shopping_lists sl
+                    LEFT JOIN LATERAL (
+                        SELECT ls.user_id, u.username
+                        FROM list_shares ls
+                        JOIN users u ON u.id = ls.user_id
+                        WHERE ls.list_id = sl.id AND ls.status = 'accepted' AND ls.permission = 'admin'
+                        ORDER BY ls.shared_at, ls.id
+                        LIMIT 1
+                    ) heir ON TRUE
+                    WHERE sl.owner_id = %s AND EXISTS (
+                        SELECT 1 FROM list_shares ls WHERE ls.list_id = sl.id AND ls.status = 'accepted'
+                    )
+                    ORDER BY sl.id
+                """, (user_id,))
+                shared_lists = cur.fetchall()
+                
+                orphaned = [{'id': l['id'], 'name': l['name']} for l in shared_lists if not l['heir_id']]
+                if orphaned and not data['delete_shared_lists']:
+                    return jsonify({
+                        'error': 'Some of your lists are shared and have no admin collaborator to take them over; '
+                                 'send delete_shared_lists to delete them with your account',
+                        'lists': orphaned
+                    }), 409
+                
+                transferred = []
+                for shared_list in shared_lists:
+                    list_id, name = shared_list['id'], shared_list['name']
+                    cur.execute(
+                        "SELECT user_id FROM list_shares WHERE list_id = %s AND status = 'accepted'",
+                        (list_id,)
+                    )
+                    members = [row['user_id'] for row in cur.fetchall()]
+                    
+                    if shared_list['heir_id']:
+                        heir_id = shared_list['heir_id']
+                        # Groups are personal, so the list leaves the old owner's group
+                        cur.execute(
+                            "UPDATE shopping_lists SET owner_id = %s, group_id = NULL WHERE id = %s",
+                            (heir_id, list_id)
+                        )
+                        cur.execute("DELETE FROM list_shares WHERE list_id = %s AND user_id = %s", (list_id, heir_id))
+                        record_list_event(cur, list_id, user_id, 'owner_changed',
+                                          {'from': user['username'], 'to': shared_list['heir_username']})
+                        for member_id in members:
+                            message = (f'{user["username"]} deleted their account; you now own "{name}"'
+                                       if member_id == heir_id else
+                                       f'{user["username"]} deleted their account; "{name}" is now owned by {shared_list["heir_username"]}')
+                            create_notification(cur, member_id, 'list_transferred', 'List Owner Changed', message,
+                                                {'list_id': list_id, 'owner_id': heir_id})
+                        transferred.append(list_id)
+                    else:
+                        for member_id in members:
+                            create_notification(
+                                cur, member_id, 'list_deleted', 'List Deleted',
+                                f'"{name}" was deleted along with {user["username"]}\'s account',
+                                {'list_id': list_id}
+                            )
+                        queue_webhook_event(cur, list_id, 'list.deleted', {'user_id': user_id, 'name': name})
+                
+                # Remaining lists, memberships, sessions and the rest go with the user (ON DELETE CASCADE)
+                cur.execute("DELETE FROM users WHERE id = %s", (user_id,))
+                
+                conn.commit()
+                
+                response = jsonify({
+                    'message': 'Account deleted',
+                    'transferred_lists': transferred,
+                    'deleted_shared_lists': [l['id'] for l in orphaned]
+                })
+                unset_jwt_cookies(response)
+                return response, 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Delete user error: {e}")
+        return jsonify({'error': 'Failed to delete account'}), 500
+
+@app.route('/api/auth/verify-email', methods=['GET', 'POST'])
+def verify_email():
+    try:
+        if request.method == 'POST':
+            token = (request.json or {}).get('token')
+        else:
+            token = request.args.get('token')
+        
+        if not token:
+            return jsonify({'error': 'Verification token is required'}), 400
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Tokens are single-use: consume it whether or not it is still valid
+                cur.execute("""
+                    DELETE FROM email_verification_tokens
+                    WHERE token_hash = %s
+                    RETURNING user_id, expires_at
+                """, (hash_token(token),))
+                
+                token_data = cur.fetchone()
+                if not token_data or token_data['expires_at'] < datetime.utcnow():
+                    conn.commit()
+                    return jsonify({'error': 'Invalid or expired verification token'}), 400
+                
+                cur.execute(
+                    "UPDATE users SET email_verified = TRUE WHERE id = %s RETURNING id, email",
+                    (token_data['user_id'],)
+                )
+                user = cur.fetchone()
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Email verified successfully',
+                    'user': {
+                        'id': user['id'],
+                        'email': user['email'],
+                        'email_verified': True
+                    }
+                }), 200
+                
+    except Exception as e:
+        print(f"Verify email error: {e}")
+        return jsonify({'error': 'Failed to verify email'}), 500
+
+@app.route('/api/auth/forgot-password', methods=['POST'])
+def forgot_password():
+    # Always answer the same way so the endpoint can't be used to discover accounts
+    response = jsonify({'message': 'If an account exists for that email, a reset link has been sent'})
+    
+    try:
+        schema = ForgotPasswordSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "SELECT id, username, email, auth_provider FROM users WHERE LOWER(email) = LOWER(%s)",
+                    (data['email'].strip(),)
+                )
+                user = cur.fetchone()
+                
+                if not user:
+                    return response, 200
+                
+                if user['auth_provider'] == 'authentik':
+                    # No local password to reset - point them at their identity provider instead
+                    mailer.send(
+                        user['email'],
+                        'Password reset requested',
+                        f"Hi {user['username']},\n\n"
+                        f"Your account signs in through Authentik, so it has no separate password here.\n"
+                        f"Please reset your password in Authentik or use \"Sign in with Authentik\"."
+                    )
+                    return response, 200
+                
+                # Only the newest link stays valid
+                token = secrets.token_urlsafe(32)
+                cur.execute("DELETE FROM password_reset_tokens WHERE user_id = %s", (user['id'],))
+                cur.execute("""
+                    INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+                    VALUES (%s, %s, %s)
+                """, (user['id'], hash_token(token), datetime.utcnow() + PASSWORD_RESET_TTL))
+                
+                conn.commit()
+                
+                mailer.send(
+                    user['email'],
+                    'Reset your password',
+                    f"Hi {user['username']},\n\n"
+                    f"Someone requested a password reset for your account. To choose a new password open:\n"
+                    f"{frontend_link(f'reset-password?token={token}')}\n\n"
+                    f"The link expires in {int(PASSWORD_RESET_TTL.total_seconds() // 60)} minutes. "
+                    f"If you didn't request this you can ignore this email."
+                )
+                
+                return response, 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Forgot password error: {e}")
+        return response, 200
+
+@app.route('/api/auth/reset-password', methods=['POST'])
+def reset_password():
+    try:
+        schema = ResetPasswordSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Tokens are single-use: consume it whether or not it is still valid
+                cur.execute("""
+                    DELETE FROM password_reset_tokens
+                    WHERE token_hash = %s
+                    RETURNING user_id, expires_at
+                """, (hash_token(data['token']),))
+                
+                token_data = cur.fetchone()
+                if not token_data or token_data['expires_at'] < datetime.utcnow():
+                    conn.commit()
+                    return jsonify({'error': 'Invalid or expired reset token'}), 400
+                
+                cur.execute("""
+                    UPDATE users SET password_hash = %s
+                    WHERE id = %s AND auth_provider <> 'authentik'
+                    RETURNING id
+                """, (hash_password(data['password']), token_data['user_id']))
+                
+                if not cur.fetchone():
+                    conn.commit()
+                    return jsonify({'error': 'This account does not use a local password'}), 400
+                
+                # Invalidate any other outstanding reset links and sign out existing sessions
+                cur.execute("DELETE FROM password_reset_tokens WHERE user_id = %s", (token_data['user_id'],))
+                cur.execute("""
+                    UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+                    WHERE user_id = %s AND revoked_at IS NULL
+                """, (token_data['user_id'],))
+                
+                conn.commit()
+                
+                return jsonify({'message': 'Password has been reset successfully'}), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Reset password error: {e}")
+        return jsonify({'error': 'Failed to reset password'}), 500
+
+@app.route('/api/auth/verify-email/resend', methods=['POST'])
+@jwt_required()
+def resend_verification_email():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "SELECT id, username, email, email_verified FROM users WHERE id = %s",
+                    (user_id,)
+                )
+                user = cur.fetchone()
+                
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                
+                if user['email_verified']:
+                    return jsonify({'error': 'Email is already verified'}), 400
+                
+                token = create_email_verification_token(cur, user_id)
+                conn.commit()
+                
+                send_verification_email(user['email'], user['username'], token)
+                
+                return jsonify({'message': 'Verification email sent'}), 200
+                
+    except Exception as e:
+        print(f"Resend verification email error: {e}")
+        return jsonify({'error': 'Failed to send verification email'}), 500
+
+# Item lookup routes
+@app.route('/api/items/by-barcode', methods=['GET'])
+@jwt_required()
+def get_item_by_barcode():
+    try:
+        user_id = int(get_jwt_identity())
+        code = request.args.get('code', '').strip()
+        if not code:
+            return jsonify({'error': 'code is required'}), 400
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Most recent item this user saved with the barcode, to prefill a new add
+                cur.execute("""
+                    SELECT name, category, priority, notes, barcode, image_url, created_at
+                    FROM shopping_list_items
+                    WHERE barcode = %s AND created_by = %s
+                    ORDER BY created_at DESC, id DESC
+                    LIMIT 1
+                """, (code, user_id))
+                
+                item = cur.fetchone()
+                if not item:
+                    return jsonify({'error': 'No item found for this barcode'}), 404
+                
+                return jsonify({'item': dict(item)})
+                
+    except Exception as e:
+        print(f"Get item by barcode error: {e}")
+        return jsonify({'error': 'Failed to look up barcode'}), 500
+
+@app.route('/api/items/mine', methods=['GET'])
+@jwt_required()
+def get_my_items():
+    try:
+        user_id = int(get_jwt_identity())
+        priority = request.args.get('priority')
+        if priority is not None and priority not in ITEM_PRIORITIES:
+            return jsonify({'error': f"priority must be one of: {', '.join(ITEM_PRIORITIES)}"}), 400
+        try:
+            list_id = int(request.args['list_id']) if request.args.get('list_id') else None
+        except ValueError:
+            return jsonify({'error': 'list_id must be an integer'}), 400
+        
+        # Uncompleted items assigned to the user, on lists they can still access
+        filters = [
+            'assigned_to = %s', 'completed = FALSE', 'deleted_at IS NULL',
+            """list_id IN (
+                SELECT id FROM shopping_lists WHERE owner_id = %s
+                UNION
+                SELECT list_id FROM list_shares WHERE user_id = %s AND status = 'accepted'
+            )"""
+        ]
+        params = [user_id, user_id, user_id]
+        if priority:
+            filters.append('priority = %s')
+            params.append(priority)
+        if list_id:
+            filters.append('list_id = %s')
+            params.append(list_id)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"""
+                    SELECT list_id, {ITEM_COLUMNS}
+                    FROM shopping_list_items
+                    WHERE {' AND '.join(filters)}
+                    ORDER BY due_date NULLS LAST, created_at DESC
+                """, params)
+                items = cur.fetchall()
+                
+                lists = {}
+                if items:
+                    cur.execute(
+                        "SELECT id, name, color, icon FROM shopping_lists WHERE id = ANY(%s) ORDER BY LOWER(name)",
+                        (list({item['list_id'] for item in items}),)
+                    )
+                    lists = {row['id']: {**dict(row), 'items': []} for row in cur.fetchall()}
+                
+                for item in items:
+                    lists[item['list_id']]['items'].append(dict(item))
+                
+                return jsonify({'lists': list(lists.values())})
+                
+    except Exception as e:
+        print(f"Get my items error: {e}")
+        return jsonify({'error': 'Failed to get your items'}), 500
+
+# Grocery memory routes
+MEMORY_SORTS = {
+    'frequency': 'usage_count DESC, last_used DESC',
+    'recency': 'last_used DESC, usage_count DESC'
+}
+
+# Lowest pg_trgm similarity (0-1) a name needs to match a fuzzy memory search
+MEMORY_FUZZY_THRESHOLD = float(os.getenv('MEMORY_FUZZY_THRESHOLD', 0.3))
+
+@app.route('/api/groceries/memory', methods=['GET'])
+@jwt_required()
+def get_grocery_memory():
+    try:
+        user_id = int(get_jwt_identity())
+        search = request.args.get('search', '')
+        limit = int(request.args.get('limit', 10))
+        priority = request.args.get('priority')
+        min_frequency = request.args.get('min_frequency')
+        fuzzy = request.args.get('fuzzy', '').lower() == 'true'
+        
+        try:
+            order_sql = parse_sort(MEMORY_SORTS, 'frequency')
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        
+        filters = ['user_id = %s']
+        params = [user_id]
+        score_sql = ''
+        
+        if search and fuzzy:
+            # Typo-tolerant: trigram similarity above the threshold, best matches first
+            score_sql = ', ROUND(similarity(name, %s)::numeric, 3)::float AS similarity'
+            filters.append('similarity(name, %s) >= %s')
+            params += [search, MEMORY_FUZZY_THRESHOLD]
+            order_sql = f'similarity DESC, {order_sql}'
+        elif search:
+            filters.append('LOWER(name) LIKE LOWER(%s)')
+            params.append(f'%{search}%')
+        
+        if priority is not None:
+            if priority not in ITEM_PRIORITIES:
+                return jsonify({'error': f"priority must be one of: {', '.join(ITEM_PRIORITIES)}"}), 400
+            filters.append('priority = %s')
+            params.append(priority)
+        
+        if min_frequency is not None:
+            if not min_frequency.isdigit() or int(min_frequency) < 1:
+                return jsonify({'error': 'min_frequency must be a positive integer'}), 400
+            filters.append('usage_count >= %s')
+            params.append(int(min_frequency))
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                try:
+                    tz = requested_timezone(cur, user_id)
+                except ValueError as e:
+                    return jsonify({'error': str(e)}), 400
+                
+                # The limit applies after sorting, so it returns the top N for the chosen order
+                cur.execute(f"""
+                    SELECT name, category, priority, usage_count, last_used{score_sql}
+                    FROM grocery_memory 
+                    WHERE {' AND '.join(filters)}
+                    ORDER BY {order_sql}
+                    LIMIT %s
+                """, ([search] if score_sql else []) + params + [limit])
+                
+                groceries = [dict(row) for row in cur.fetchall()]
+                if tz:
+                    for grocery in groceries:
+                        grocery['last_used_local'] = localize_timestamp(grocery['last_used'], tz)
+                
+                return jsonify({
+                    'groceries': groceries
+                })
+                
+    except Exception as e:
+        print(f"Get grocery memory error: {e}")
+        return jsonify({'error': 'Failed to get grocery memory'}), 500
+
+@app.route('/api/groceries/memory', methods=['DELETE'])
+@jwt_required()
+def clear_grocery_memory():
+    try:
+        user_id = int(get_jwt_identity())
+        category = normalize_category(request.args.get('category'))
+        
+        filters = ['user_id = %s']
+        params = [user_id]
+        if category:
+            filters.append('category = %s')
+            params.append(category)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Only suggestions are forgotten; items on lists are left alone
+                cur.execute(f"DELETE FROM grocery_memory WHERE {' AND '.join(filters)}", params)
+                cleared_count = cur.rowcount
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Grocery memory cleared',
+                    'cleared_count': cleared_count
+                })
+                
+    except Exception as e:
+        print(f"Clear grocery memory error: {e}")
+        return jsonify({'error': 'Failed to clear grocery memory'}), 500
+
+def category_color_map(cur, user_id):
+    """The user's category -> color mapping"""
+    cur.execute("SELECT category, color FROM category_colors WHERE user_id = %s ORDER BY category", (user_id,))
+    return {row['category']: row['color'] for row in cur.fetchall()}
+
+@app.route('/api/groceries/memory/category-colors', methods=['GET'])
+@jwt_required()
+def get_category_colors():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                return jsonify({'colors': category_color_map(cur, user_id)})
+                
+    except Exception as e:
+        print(f"Get category colors error: {e}")
+        return jsonify({'error': 'Failed to get category colors'}), 500
+
+@app.route('/api/groceries/memory/category-colors', methods=['PUT'])
+@jwt_required()
+def set_category_colors():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = CategoryColorsSchema()
+        data = schema.load(request.json or {})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "DELETE FROM category_colors WHERE user_id = %s AND NOT (category = ANY(%s))",
+                    (user_id, list(data['colors']))
+                )
+                for category, color in data['colors'].items():
+                    cur.execute("""
+                        INSERT INTO category_colors (user_id, category, color)
+                        VALUES (%s, %s, %s)
+                        ON CONFLICT (user_id, category)
+                        DO UPDATE SET color = EXCLUDED.color, updated_at = CURRENT_TIMESTAMP
+                    """, (user_id, category, color))
+                
+                colors = category_color_map(cur, user_id)
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Category colors updated',
+                    'colors': colors
+                })
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Set category colors error: {e}")
+        return jsonify({'error': 'Failed to update category colors'}), 500
+
+@app.route('/api/groceries/frequent', methods=['GET'])
+@jwt_required()
+def get_frequent_groceries():
+    try:
+        user_id = int(get_jwt_identity())
+        limit = int(request.args.get('limit', 8))
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                try:
+                    tz = requested_timezone(cur, user_id)
+                except ValueError as e:
+                    return jsonify({'error': str(e)}), 400
+                
+                cur.execute("""
+                    SELECT name, category, priority, usage_count, last_used
+                    FROM grocery_memory 
+                    WHERE user_id = %s
+                    ORDER BY usage_count DESC, last_used DESC 
+                    LIMIT %s
+                """, (user_id, limit))
+                
+                groceries = [dict(row) for row in cur.fetchall()]
+                if tz:
+                    for grocery in groceries:
+                        grocery['last_used_local'] = localize_timestamp(grocery['last_used'], tz)
+                
+                return jsonify({
+                    'groceries': groceries
+                })
+                
+    except Exception as e:
+        print(f"Get frequent groceries error: {e}")
+        return jsonify({'error': 'Failed to get frequent groceries'}), 500
+
+@app.route('/api/groceries/categories', methods=['GET'])
+@jwt_required()
+def get_grocery_categories():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT category, COUNT(*) as item_count, COALESCE(SUM(usage_count), 0) as total_usage
+                    FROM grocery_memory
+                    WHERE user_id = %s
+                    GROUP BY category
+                    ORDER BY total_usage DESC, category
+                """, (user_id,))
+                
+                categories = cur.fetchall()
+                
+                return jsonify({
+                    'categories': [dict(row) for row in categories]
+                })
+                
+    except Exception as e:
+        print(f"Get grocery categories error: {e}")
+        return jsonify({'error': 'Failed to get grocery categories'}), 500
+
+@app.route('/api/groceries/merge-category', methods=['POST'])
+@jwt_required()
+def merge_grocery_category():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = CategoryMergeSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Both rewrites commit together or not at all
+                cur.execute("""
+                    UPDATE grocery_memory SET category = %s
+                    WHERE user_id = %s AND category = %s
+                """, (data['to_category'], user_id, data['from_category']))
+                memory_updated = cur.rowcount
+                
+                cur.execute("""
+                    UPDATE shopping_list_items sli SET category = %s
+                    FROM shopping_lists sl
+                    WHERE sl.id = sli.list_id AND sl.owner_id = %s AND sli.category = %s
+                    RETURNING sli.id, sli.list_id
+                """, (data['to_category'], user_id, data['from_category']))
+                items = cur.fetchall()
+                for item in items:
+                    record_item_history(cur, item['list_id'], item['id'], user_id, 'updated',
+                                        {'category': data['from_category']}, {'category': data['to_category']})
+                items_updated = len(items)
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Category merged successfully',
+                    'memory_updated': memory_updated,
+                    'items_updated': items_updated
+                })
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Merge grocery category error: {e}")
+        return jsonify({'error': 'Failed to merge category'}), 500
+
+@app.route('/api/groceries/stats', methods=['GET'])
+@jwt_required()
+def get_grocery_stats():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT 
+                        COUNT(*) as total_items,
+                        COALESCE(SUM(usage_count), 0) as total_usage,
+                        COALESCE(AVG(usage_count), 0) as avg_usage
+                    FROM grocery_memory 
+                    WHERE user_id = %s
+                """, (user_id,))
+                
+                stats = cur.fetchone()
+                
+                return jsonify({
+                    'stats': {
+                        'totalItems': stats['total_items'],
+                        'totalUsage': stats['total_usage'],
+                        'averageUsage': round(float(stats['avg_usage']), 1)
+                    }
+                })
+                
+    except Exception as e:
+        print(f"Get grocery stats error: {e}")
+        return jsonify({'error': 'Failed to get grocery statistics'}), 500
+
+# Favorite item routes
+FAVORITE_COLUMNS = "id, name, category, priority, quantity, created_at, updated_at"
+
+@app.route('/api/favorites', methods=['GET'])
+@jwt_required()
+def get_favorite_items():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"""
+                    SELECT {FAVORITE_COLUMNS}
+                    FROM favorite_items
+                    WHERE user_id = %s
+                    ORDER BY LOWER(name)
+                """, (user_id,))
+                
+                favorites = cur.fetchall()
+                
+                return jsonify({
+                    'favorites': [dict(favorite) for favorite in favorites]
+                })
+                
+    except Exception as e:
+        print(f"Get favorite items error: {e}")
+        return jsonify({'error': 'Failed to get favorite items'}), 500
+
+@app.route('/api/favorites', methods=['POST'])
+@jwt_required()
+def create_favorite_item():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = FavoriteItemSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("SELECT COUNT(*) AS count FROM favorite_items WHERE user_id = %s", (user_id,))
+                if cur.fetchone()['count'] >= MAX_FAVORITES_PER_USER:
+                    return jsonify({'error': f'You can have at most {MAX_FAVORITES_PER_USER} favorite items'}), 400
+                
+                cur.execute(f"""
+                    INSERT INTO favorite_items (user_id, name, category, priority, quantity)
+                    VALUES (%s, %s, %s, %s, %s)
+                    ON CONFLICT (user_id, name) DO NOTHING
+                    RETURNING {FAVORITE_COLUMNS}
+                """, (user_id, data['name'], data['category'], data['priority'], data['quantity']))
+                
+                favorite = cur.fetchone()
+                if not favorite:
+                    return jsonify({'error': 'A favorite item with this name already exists'}), 409
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Favorite item created',
+                    'favorite': dict(favorite)
+                }), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Create favorite item error: {e}")
+        return jsonify({'error': 'Failed to create favorite item'}), 500
+
+@app.route('/api/favorites/<int:favorite_id>', methods=['PUT', 'PATCH'])
+@jwt_required()
+def update_favorite_item(favorite_id):
+    try:
+        user_id = int(get_jwt_identity())
+        # Partial load: only the fields that were sent are changed
+        schema = FavoriteItemSchema(partial=True)
+        data = schema.load(request.json or {})
+        if not data:
+            return jsonify({'error': 'No fields to update'}), 400
+        
+        assignments = ', '.join(f'{field} = %s' for field in data)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"""
+                    UPDATE favorite_items SET {assignments}
+                    WHERE id = %s AND user_id = %s
+                    RETURNING {FAVORITE_COLUMNS}
+                """, list(data.values()) + [favorite_id, user_id])
+                
+                favorite = cur.fetchone()
+                if not favorite:
+                    return jsonify({'error': 'Favorite item not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Favorite item updated',
+                    'favorite': dict(favorite)
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except psycopg2.IntegrityError:
+        return jsonify({'error': 'A favorite item with this name already exists'}), 409
+    except Exception as e:
+        print(f"Update favorite item error: {e}")
+        return jsonify({'error': 'Failed to update favorite item'}), 500
+
+@app.route('/api/favorites/<int:favorite_id>', methods=['DELETE'])
+@jwt_required()
+def delete_favorite_item(favorite_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "DELETE FROM favorite_items WHERE id = %s AND user_id = %s",
+                    (favorite_id, user_id)
+                )
+                
+                if cur.rowcount == 0:
+                    return jsonify({'error': 'Favorite item not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({'message': 'Favorite item deleted successfully'}), 200
+                
+    except Exception as e:
+        print(f"Delete favorite item error: {e}")
+        return jsonify({'error': 'Failed to delete favorite item'}), 500
+
+# Statistics routes
+@app.route('/api/stats/overview', methods=['GET'])
+@jwt_required()
+def get_stats_overview():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Progress across every list the user owns, in one round-trip
+                cur.execute("""
+                    SELECT 
+                        COUNT(DISTINCT sl.id) as total_lists,
+                        COUNT(sli.id) as total_items,
+                        COUNT(CASE WHEN sli.completed = true THEN 1 END) as completed_items,
+                        COUNT(CASE WHEN sli.created_at >= CURRENT_TIMESTAMP - INTERVAL '7 days' THEN 1 END) as items_added_7d,
+                        COUNT(CASE WHEN sli.created_at >= CURRENT_TIMESTAMP - INTERVAL '30 days' THEN 1 END) as items_added_30d
+                    FROM shopping_lists sl
+                    LEFT JOIN shopping_list_items sli ON sl.id = sli.list_id AND sli.deleted_at IS NULL
+                    WHERE sl.owner_id = %s
+                """, (user_id,))
+                
+                stats = cur.fetchone()
+                total_items = stats['total_items']
+                
+                return jsonify({
+                    'stats': {
+                        'totalLists': stats['total_lists'],
+                        'totalItems': total_items,
+                        'completedItems': stats['completed_items'],
+                        'completionPercentage': round(stats['completed_items'] * 100 / total_items, 1) if total_items else 0,
+                        'itemsAddedLast7Days': stats['items_added_7d'],
+                        'itemsAddedLast30Days': stats['items_added_30d']
+                    }
+                })
+                
+    except Exception as e:
+        print(f"Get stats overview error: {e}")
+        return jsonify({'error': 'Failed to get statistics overview'}), 500
+
+# Shopping list routes
+@app.route('/api/lists', methods=['GET'])
+@jwt_required()
+def get_shopping_lists():
+    try:
+        user_id = int(get_jwt_identity())
+        group_id = request.args.get('group_id')
+        search = request.args.get('q', '').strip()
+        shared_only = request.args.get('shared', '').lower() == 'true'
+        
+        try:
+            sort_sql = parse_sort(LIST_SORT_COLUMNS, 'updated_at')
+            order = parse_order()
+            limit, offset = parse_pagination()
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        
+        filters = []
+        params = [user_id, user_id]
+        
+        # Groups are personal to the owner, so filtering only ever matches owned lists
+        if group_id:
+            if not group_id.isdigit():
+                return jsonify({'error': 'group_id must be a number'}), 400
+            filters.append('group_id = %s')
+            params.append(int(group_id))
+        
+        if search:
+            filters.append('name ILIKE %s')
+            params.append(f'%{search}%')
+        
+        if shared_only:
+            filters.append("role <> 'owner'")
+        
+        where = f"WHERE {' AND '.join(filters)}" if filters else ''
+        
+        # Owned and accepted shared lists
+        lists_sql = f"""
+            SELECT * FROM (
+                SELECT 
+                    sl.id, sl.name, sl.color, sl.icon, sl.is_shared, sl.created_at, sl.updated_at,
+                    COUNT(sli.id) as item_count,
+                    COUNT(CASE WHEN sli.completed = true THEN 1 END) as completed_count,
+                    {LIST_SHARE_COUNT} as share_count,
+                    {LIST_LAST_ACTIVITY} as last_activity_at,
+                    COALESCE((sl.id = u.default_list_id), false) as is_default,
+                    'owner' as role,
+                    true as can_write,
+                    u.username as owner_username,
+                    sl.group_id, lg.name as group_name
+                FROM shopping_lists sl
+                LEFT JOIN shopping_list_items sli ON sl.id = sli.list_id AND sli.deleted_at IS NULL
+                LEFT JOIN users u ON u.id = sl.owner_id
+                LEFT JOIN list_groups lg ON lg.id = sl.group_id
+                WHERE sl.owner_id = %s
+                GROUP BY sl.id, u.default_list_id, u.username, lg.name
+                
+                UNION
+                
+                SELECT 
+                    sl.id, sl.name, sl.color, sl.icon, sl.is_shared, sl.created_at, sl.updated_at,
+                    COUNT(sli.id) as item_count,
+                    COUNT(CASE WHEN sli.completed = true THEN 1 END) as completed_count,
+                    {LIST_SHARE_COUNT} as share_count,
+                    {LIST_LAST_ACTIVITY} as last_activity_at,
+                    false as is_default,
+                    ls.permission as role,
+                    ls.permission IN ('write', 'admin') as can_write,
+                    u.username as owner_username,
+                    NULL::integer as group_id, NULL as group_name
+                FROM shopping_lists sl
+                LEFT JOIN shopping_list_items sli ON sl.id = sli.list_id AND sli.deleted_at IS NULL
+                LEFT JOIN users u ON u.id = sl.owner_id
+                INNER JOIN list_shares ls ON ls.list_id = sl.id
+                WHERE ls.user_id = %s AND ls.status = 'accepted'
+                GROUP BY sl.id, ls.permission, u.username
+            ) AS lists
+            {where}
+        """
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"SELECT COUNT(*) AS total FROM ({lists_sql}) AS counted", params)
+                total = cur.fetchone()['total']
+                
+                page_sql = ''
+                page_params = []
+                if limit is not None:
+                    page_sql = 'LIMIT %s OFFSET %s'
+                    page_params = [limit, offset]
+                
+                cur.execute(f"""
+                    {lists_sql}
+                    ORDER BY {sort_sql} {order}, id {order}
+                    {page_sql}
+                """, params + page_params)
+                
+                lists = cur.fetchall()
+                
+                return jsonify({
+                    'lists': [dict(row) for row in lists],
+                    'total': total,
+                    'limit': limit,
+                    'offset': offset
+                })
+                
+    except Exception as e:
+        print(f"Get shopping lists error: {e}")
+        return jsonify({'error': 'Failed to get shopping lists'}), 500
+
+@app.route('/api/lists', methods=['POST'])
+@jwt_required()
+def create_shopping_list():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ShoppingListSchema()
+        data = schema.load(request.json or {})
+        
+        name = data['name']
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Claimed before the quota check so a retry of a create that used the last slot replays
+                idempotency_key, replay = claim_idempotency_key(cur, user_id)
+                if replay:
+                    return replay
+                
+                cur.execute("SELECT is_guest FROM users WHERE id = %s", (user_id,))
+                max_lists = GUEST_MAX_LISTS if cur.fetchone()['is_guest'] else MAX_LISTS_PER_USER
+                cur.execute("SELECT COUNT(*) AS count FROM shopping_lists WHERE owner_id = %s", (user_id,))
+                if cur.fetchone()['count'] >= max_lists:
+                    conn.rollback()
+                    return jsonify({'error': f'You can own at most {max_lists} shopping lists'}), 403
+                
+                cur.execute("""
+                    INSERT INTO shopping_lists (name, owner_id, color, icon)
+                    VALUES (%s, %s, %s, %s)
+                    RETURNING id, name, color, icon, is_shared, created_at, updated_at
+                """, (name, user_id, data.get('color'), data.get('icon')))
+                
+                list_data = cur.fetchone()
+                body = {
+                    'message': 'Shopping list created',
+                    'list': dict(list_data)
+                }
+                if idempotency_key:
+                    save_idempotent_response(cur, user_id, idempotency_key, body, 201)
+                conn.commit()
+                
+                return jsonify(body), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Create shopping list error: {e}")
+        return jsonify({'error': 'Failed to create shopping list'}), 500
+
+@app.route('/api/lists/batch', methods=['POST'])
+@jwt_required()
+def get_shopping_lists_batch():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ListBatchSchema()
+        data = schema.load(request.json)
+        
+        # Keep the requested order, ignoring repeated ids
+        list_ids = list(dict.fromkeys(data['ids']))
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Lists the user can't access are silently skipped
+                cur.execute(f"""
+                    SELECT sl.id, sl.name, sl.color, sl.icon, sl.is_shared, sl.created_at, sl.updated_at, 
+                           CASE 
+                               WHEN sl.owner_id = %s THEN 'admin'
+                               ELSE ls.permission
+                           END as user_permission,
+                           CASE WHEN sl.owner_id = %s THEN TRUE ELSE FALSE END as is_owner,
+                           (sl.owner_id = %s OR ls.permission IN ('write', 'admin')) as can_write,
+                           owner.username as owner_username,
+                           {LIST_SHARE_COUNT} as share_count
+                    FROM shopping_lists sl
+                    JOIN users owner ON owner.id = sl.owner_id
+                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    WHERE sl.id = ANY(%s) AND (sl.owner_id = %s OR ls.id IS NOT NULL)
+                """, (user_id, user_id, user_id, user_id, list_ids, user_id))
+                
+                lists = {row['id']: {**dict(row), 'items': []} for row in cur.fetchall()}
+                
+                if lists:
+                    cur.execute(f"""
+                        SELECT list_id, {ITEM_COLUMNS}
+                        FROM shopping_list_items
+                        WHERE list_id = ANY(%s) AND deleted_at IS NULL
+                        ORDER BY created_at DESC
+                    """, (list(lists),))
+                    
+                    for item in cur.fetchall():
+                        item = dict(item)
+                        lists[item.pop('list_id')]['items'].append(item)
+                
+                return jsonify({
+                    'lists': [lists[list_id] for list_id in list_ids if list_id in lists]
+                })
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Get shopping lists batch error: {e}")
+        return jsonify({'error': 'Failed to get shopping lists'}), 500
+
+@app.route('/api/lists/<int:list_id>', methods=['GET'])
+@jwt_required()
+def get_shopping_list(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Get list info and user's permission (check both owned and shared lists)
+                cur.execute(f"""
+                    SELECT sl.id, sl.name, sl.color, sl.icon, sl.is_shared, sl.created_at, sl.updated_at, 
+                           CASE 
+                               WHEN sl.owner_id = %s THEN 'admin'
+                               ELSE ls.permission
+                           END as user_permission,
+                           CASE WHEN sl.owner_id = %s THEN TRUE ELSE FALSE END as is_owner,
+                           (sl.owner_id = %s OR ls.permission IN ('write', 'admin')) as can_write,
+                           owner.username as owner_username,
+                           {LIST_SHARE_COUNT} as share_count
+                    FROM shopping_lists sl
+                    JOIN users owner ON owner.id = sl.owner_id
+                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    WHERE sl.id = %s AND (sl.owner_id = %s OR ls.id IS NOT NULL)
+                """, (user_id, user_id, user_id, user_id, list_id, user_id))
+                
+                list_data = cur.fetchone()
+                if not list_data:
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                list_data = dict(list_data)
+                
+                # Only owners and admins see who the list is shared with
+                if list_data['user_permission'] == 'admin':
+                    cur.execute("""
+                        SELECT u.username, ls.permission
+                        FROM list_shares ls
+                        JOIN users u ON u.id = ls.user_id
+                        WHERE ls.list_id = %s AND ls.status = 'accepted'
+                        ORDER BY ls.shared_at
+                    """, (list_id,))
+                    list_data['shared_with'] = [dict(share) for share in cur.fetchall()]
+                
+                # Get list items
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND deleted_at IS NULL
+                    ORDER BY created_at DESC
+                """, (list_id,))
+                
+                items = cur.fetchall()
+                
+                return conditional_json({
+                    'list': {
+                        **dict(list_data),
+                        'items': [dict(item) for item in items]
+                    }
+                })
+                
+    except Exception as e:
+        print(f"Get shopping list error: {e}")
+        return jsonify({'error': 'Failed to get shopping list'}), 500
+
+@app.route('/api/lists/<int:list_id>/summary', methods=['GET'])
+@jwt_required()
+def get_list_summary(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not is_list_member(cur, list_id, user_id):
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                # Remaining items per configured priority, in one round-trip
+                priority_counts = ''.join(
+                    f", COUNT(CASE WHEN completed = false AND priority = %s THEN 1 END) as remaining_{index}"
+                    for index in range(len(ITEM_PRIORITIES))
+                )
+                cur.execute(f"""
+                    SELECT 
+                        COUNT(*) as total_items,
+                        COUNT(CASE WHEN completed = true THEN 1 END) as completed_items{priority_counts}
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND deleted_at IS NULL
+                """, (*ITEM_PRIORITIES, list_id))
+                
+                summary = cur.fetchone()
+                
+                return jsonify({
+                    'summary': {
+                        'total_items': summary['total_items'],
+                        'completed_items': summary['completed_items'],
+                        'remaining_items': summary['total_items'] - summary['completed_items'],
+                        'remaining_by_priority': {
+                            priority: summary[f'remaining_{index}']
+                            for index, priority in enumerate(ITEM_PRIORITIES)
+                        }
+                    }
+                })
+                
+    except Exception as e:
+        print(f"Get list summary error: {e}")
+        return jsonify({'error': 'Failed to get list summary'}), 500
+
+# ITEM_CATEGORIES is laid out in store-walking order; it is inlined as a literal array so
+# sort expressions stay plain SQL without placeholders of their own
+ITEM_CATEGORY_ORDER = 'ARRAY[{}]::text[]'.format(
+    ', '.join("'{}'".format(category.replace("'", "''")) for category in ITEM_CATEGORIES)
+)
+
+ITEM_SORTS = {
+    'created_at': 'created_at DESC',
+    'category': f'array_position({ITEM_CATEGORY_ORDER}, category), created_at DESC'
+}
+
+@app.route('/api/lists/<int:list_id>/items', methods=['GET'])
+@jwt_required()
+def get_list_items(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        assigned_to = request.args.get('assigned_to')
+        due_only = request.args.get('due', '').lower() == 'true'
+        tag = request.args.get('tag', '').strip().lower()
+        group_by = request.args.get('group_by')
+        include_colors = request.args.get('include_colors', '').lower() == 'true'
+        
+        try:
+            order_sql = parse_sort(ITEM_SORTS, 'created_at')
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        if group_by not in (None, 'category'):
+            return jsonify({'error': 'group_by must be category'}), 400
+        if group_by:
+            order_sql = ITEM_SORTS['category']
+        
+        completed_since = request.args.get('completed_since')
+        if completed_since is not None:
+            try:
+                completed_since = datetime.fromisoformat(completed_since)
+            except ValueError:
+                return jsonify({'error': 'completed_since must be an ISO 8601 timestamp'}), 400
+            if completed_since.tzinfo is not None:
+                completed_since = completed_since.astimezone(timezone.utc).replace(tzinfo=None)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not is_list_member(cur, list_id, user_id):
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                filters = ['list_id = %s', 'deleted_at IS NULL']
+                params = [list_id]
+                
+                if assigned_to:
+                    if assigned_to == 'me':
+                        assigned_to = user_id
+                    elif not assigned_to.isdigit():
+                        return jsonify({'error': 'assigned_to must be a user id or "me"'}), 400
+                    filters.append('assigned_to = %s')
+                    params.append(int(assigned_to))
+                
+                if due_only:
+                    filters.append('completed = FALSE AND due_date <= CURRENT_DATE')
+                
+                if completed_since:
+                    filters.append('completed_at >= %s')
+                    params.append(completed_since)
+                
+                if tag:
+                    filters.append("""EXISTS (
+                        SELECT 1 FROM shopping_list_item_tags it
+                        JOIN item_tags t ON t.id = it.tag_id
+                        WHERE it.item_id = shopping_list_items.id AND t.name = %s
+                    )""")
+                    params.append(tag)
+                
+                # Colors come from the requesting user's mapping, so collaborators can each use their own
+                color_sql = ''
+                if include_colors:
+                    color_sql = """,
+                        (SELECT cc.color FROM category_colors cc
+                         WHERE cc.user_id = %s AND cc.category = shopping_list_items.category) AS category_color"""
+                    params.insert(0, user_id)
+                
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}{color_sql}
+                    FROM shopping_list_items
+                    WHERE {' AND '.join(filters)}
+                    ORDER BY {order_sql}
+                """, params)
+                
+                items = cur.fetchall()
+                
+                if group_by:
+                    groups = []
+                    for item in items:
+                        if not groups or groups[-1]['category'] != item['category']:
+                            groups.append({'category': item['category'], 'items': []})
+                        groups[-1]['items'].append(dict(item))
+                    return conditional_json({'groups': groups})
+                
+                return conditional_json({
+                    'items': [dict(item) for item in items]
+                })
+                
+    except Exception as e:
+        print(f"Get list items error: {e}")
+        return jsonify({'error': 'Failed to get list items'}), 500
+
+@app.route('/api/lists/<int:list_id>/items', methods=['POST'])
+@jwt_required()
+def add_list_item(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        force = request.args.get('force', '').lower() == 'true'
+        schema = ShoppingListItemSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                assigned_to = data.get('assigned_to')
+                if assigned_to and not is_list_member(cur, list_id, assigned_to):
+                    return jsonify({'error': 'Items can only be assigned to the list owner or its collaborators'}), 400
+                
+                idempotency_key, replay = claim_idempotency_key(cur, user_id)
+                if replay:
+                    return replay
+                
+                # Quota and duplicate checks come after the claim so a retried create
+                # replays instead of hitting the limit or finding its own item
+                if item_quota_exceeded(cur, list_id):
+                    conn.rollback()
+                    return item_quota_response(cur, list_id)
+                
+                if not force:
+                    cur.execute(f"""
+                        SELECT {ITEM_COLUMNS}
+                        FROM shopping_list_items
+                        WHERE list_id = %s AND LOWER(name) = LOWER(%s) AND completed = FALSE AND deleted_at IS NULL
+                        ORDER BY created_at
+                        LIMIT 1
+                    """, (list_id, data['name']))
+                    existing = cur.fetchone()
+                    if existing:
+                        conn.rollback()
+                        return jsonify({
+                            'error': 'An item with this name is already on the list',
+                            'item': dict(existing)
+                        }), 409
+                
+                item = insert_list_item(cur, list_id, user_id, data)
+                
+                body = {
+                    'message': 'Item added to shopping list',
+                    'item': dict(item)
+                }
+                if idempotency_key:
+                    save_idempotent_response(cur, user_id, idempotency_key, body, 201)
+                conn.commit()
+                
+                return jsonify(body), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Add item error: {e}")
+        return jsonify({'error': 'Failed to add item to shopping list'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/add-or-increment', methods=['POST'])
+@jwt_required()
+def add_or_increment_list_item(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ShoppingListItemSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                assigned_to = data.get('assigned_to')
+                if assigned_to and not is_list_member(cur, list_id, assigned_to):
+                    return jsonify({'error': 'Items can only be assigned to the list owner or its collaborators'}), 400
+                
+                # Locking the list serializes concurrent adds, so two requests can't both insert the same item
+                cur.execute("SELECT id FROM shopping_lists WHERE id = %s FOR UPDATE", (list_id,))
+                
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND LOWER(name) = LOWER(%s) AND category = %s
+                      AND completed = FALSE AND deleted_at IS NULL
+                    ORDER BY created_at
+                    LIMIT 1
+                """, (list_id, data['name'], data['category']))
+                before = cur.fetchone()
+                
+                if before:
+                    cur.execute(f"""
+                        UPDATE shopping_list_items
+                        SET quantity = quantity + %s
+                        WHERE id = %s
+                        RETURNING {ITEM_COLUMNS}
+                    """, (data['quantity'], before['id']))
+                    item = dict(cur.fetchone())
+                    record_item_history(cur, list_id, item['id'], user_id, 'updated', before, item)
+                    conn.commit()
+                    
+                    return jsonify({
+                        'message': 'Item quantity increased',
+                        'item': item,
+                        'incremented': True
+                    }), 200
+                
+                if item_quota_exceeded(cur, list_id):
+                    return item_quota_response(cur, list_id)
+                
+                item = insert_list_item(cur, list_id, user_id, data)
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item added to shopping list',
+                    'item': item,
+                    'incremented': False
+                }), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Add or increment item error: {e}")
+        return jsonify({'error': 'Failed to add item to shopping list'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/from-favorite/<int:favorite_id>', methods=['POST'])
+@jwt_required()
+def add_list_item_from_favorite(list_id, favorite_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute(
+                    "SELECT name, category, priority, quantity FROM favorite_items WHERE id = %s AND user_id = %s",
+                    (favorite_id, user_id)
+                )
+                favorite = cur.fetchone()
+                if not favorite:
+                    return jsonify({'error': 'Favorite item not found'}), 404
+                
+                if item_quota_exceeded(cur, list_id):
+                    return item_quota_response(cur, list_id)
+                
+                item = insert_list_item(cur, list_id, user_id, dict(favorite, notes=''))
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item added to shopping list',
+                    'item': item
+                }), 201
+                
+    except Exception as e:
+        print(f"Add item from favorite error: {e}")
+        return jsonify({'error': 'Failed to add favorite item to shopping list'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/bulk', methods=['POST'])
+@jwt_required()
+def add_list_items_bulk(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ItemBulkSchema()
+        # Errors are reported per index (items.<index>.<field>) and reject the whole batch
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                for index, item_data in enumerate(data['items']):
+                    assigned_to = item_data.get('assigned_to')
+                    if assigned_to and not is_list_member(cur, list_id, assigned_to):
+                        raise ValidationError(
+                            {'items': {index: {'assigned_to': ['Items can only be assigned to the list owner or its collaborators']}}}
+                        )
+                
+                idempotency_key, replay = claim_idempotency_key(cur, user_id)
+                if replay:
+                    return replay
+                
+                # Checked after the claim so a retry of a bulk add that filled the list replays
+                if item_quota_exceeded(cur, list_id, len(data['items'])):
+                    conn.rollback()
+                    return item_quota_response(cur, list_id)
+                
+                items = [insert_list_item(cur, list_id, user_id, item_data) for item_data in data['items']]
+                
+                body = {
+                    'message': f'{len(items)} items added to shopping list',
+                    'items': items
+                }
+                if idempotency_key:
+                    save_idempotent_response(cur, user_id, idempotency_key, body, 201)
+                conn.commit()
+                
+                return jsonify(body), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Bulk add items error: {e}")
+        return jsonify({'error': 'Failed to add items to shopping list'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/bulk', methods=['PATCH'])
+@jwt_required()
+def update_list_items_bulk(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ItemBulkUpdateSchema()
+        data = schema.load(request.json)
+        
+        # Errors are reported per index (items.<index>.updates.<field>) and reject the whole batch
+        updates_schema = ShoppingListItemSchema(partial=True, only=BULK_UPDATE_ITEM_FIELDS)
+        entries = []
+        seen_ids = set()
+        for index, entry in enumerate(data['items']):
+            try:
+                updates = updates_schema.load(entry['updates'])
+            except ValidationError as e:
+                raise ValidationError({'items': {index: {'updates': e.messages}}})
+            if not updates:
+                raise ValidationError({'items': {index: {'updates': ['No fields to update.']}}})
+            if entry['id'] in seen_ids:
+                raise ValidationError({'items': {index: {'id': ['Duplicate item id.']}}})
+            seen_ids.add(entry['id'])
+            entries.append((index, entry['id'], updates))
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute("""
+                    SELECT id, quantity, category, priority, completed, completed_by, completed_at, assigned_to
+                    FROM shopping_list_items
+                    WHERE id = ANY(%s) AND list_id = %s AND deleted_at IS NULL
+                    FOR UPDATE
+                """, (list(seen_ids), list_id))
+                current = {row['id']: row for row in cur.fetchall()}
+                
+                for index, item_id, updates in entries:
+                    if item_id not in current:
+                        raise ValidationError({'items': {index: {'id': ['Item not found on this list.']}}})
+                    assigned_to = updates.get('assigned_to')
+                    if assigned_to and not is_list_member(cur, list_id, assigned_to):
+                        raise ValidationError(
+                            {'items': {index: {'updates': {'assigned_to': ['Items can only be assigned to the list owner or its collaborators']}}}}
+                        )
+                
+                items = []
+                snapshot = []
+                for index, item_id, updates in entries:
+                    before = current[item_id]
+                    fields_to_update = [field for field in BULK_UPDATE_ITEM_FIELDS if field in updates]
+                    set_clause = ', '.join(f'{field} = %s' for field in fields_to_update)
+                    params = [updates[field] for field in fields_to_update]
+                    restore = {field: before[field] for field in fields_to_update}
+                    if 'completed' in updates:
+                        # Same completed_by rule as a single update
+                        set_clause += ', completed_by = CASE WHEN NOT %s THEN NULL WHEN NOT completed THEN %s ELSE completed_by END'
+                        params += [updates['completed'], user_id]
+                        restore['completed_by'] = before['completed_by']
+                        # ISO text, which Postgres reads back exactly on undo
+                        restore['completed_at'] = before['completed_at'] and before['completed_at'].isoformat()
+                    
+                    cur.execute(f"""
+                        UPDATE shopping_list_items
+                        SET {set_clause}
+                        WHERE id = %s AND list_id = %s
+                        RETURNING {ITEM_COLUMNS}
+                    """, (*params, item_id, list_id))
+                    
+                    item = dict(cur.fetchone())
+                    record_item_history(cur, list_id, item_id, user_id, 'updated', before, item)
+                    if item['assigned_to'] != before['assigned_to']:
+                        notify_item_assigned(cur, list_id, item, user_id)
+                    if item['completed'] and not before['completed']:
+                        schedule_recurrence(cur, list_id, item, user_id)
+                    
+                    items.append(item)
+                    snapshot.append({'id': item_id, 'before': restore})
+                
+                operation_id = record_item_operation(cur, list_id, user_id, 'bulk_update', {'items': snapshot})
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'{len(items)} items updated',
+                    'items': items,
+                    'operation_id': operation_id
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Bulk update items error: {e}")
+        return jsonify({'error': 'Failed to update items'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>/duplicate', methods=['POST'])
+@jwt_required()
+def duplicate_list_item(list_id, item_id):
+    try:
+        user_id = int(get_jwt_identity())
+        # Optional overrides for the copy; anything omitted is taken from the original
+        schema = ShoppingListItemSchema(partial=True, only=DUPLICATE_ITEM_FIELDS)
+        overrides = schema.load(request.get_json(silent=True) or {})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute("""
+                    SELECT name, quantity, amount, category, priority, notes
+                    FROM shopping_list_items
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                """, (item_id, list_id))
+                original = cur.fetchone()
+                if not original:
+                    return jsonify({'error': 'Item not found'}), 404
+                if item_quota_exceeded(cur, list_id):
+                    return item_quota_response(cur, list_id)
+                
+                data = {**original, **overrides}
+                cur.execute(f"""
+                    INSERT INTO shopping_list_items (list_id, name, quantity, amount, category, priority, notes, created_by)
+                    VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+                    RETURNING {ITEM_COLUMNS}
+                """, (list_id, data['name'], data['quantity'], data['amount'], data['category'], data['priority'],
+                      data['notes'], user_id))
+                
+                item = dict(cur.fetchone())
+                record_item_history(cur, list_id, item['id'], user_id, 'created', after=item)
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item duplicated successfully',
+                    'item': item
+                }), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Duplicate item error: {e}")
+        return jsonify({'error': 'Failed to duplicate item'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>', methods=['PUT'])
+@jwt_required()
+def update_list_item(list_id, item_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ShoppingListItemSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                assigned_to = data.get('assigned_to')
+                if assigned_to and not is_list_member(cur, list_id, assigned_to):
+                    return jsonify({'error': 'Items can only be assigned to the list owner or its collaborators'}), 400
+                
+                # Lock the current row so the history diff matches what we overwrite
+                cur.execute("""
+                    SELECT name, quantity, amount, category, priority, notes, completed,
+                           assigned_to, recurring, recur_interval_days, due_date, image_url, barcode, version
+                    FROM shopping_list_items
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                    FOR UPDATE
+                """, (item_id, list_id))
+                before = cur.fetchone()
+                if not before:
+                    return jsonify({'error': 'Item not found'}), 404
+                
+                # Someone else saved the item since the client read it
+                if 'expected_version' in data and data['expected_version'] != before['version']:
+                    cur.execute(f"SELECT {ITEM_COLUMNS} FROM shopping_list_items WHERE id = %s", (item_id,))
+                    return jsonify({
+                        'error': 'Item was modified by someone else',
+                        'item': dict(cur.fetchone())
+                    }), 409
+                
+                # Optional fields are only changed when sent; an explicit null clears them
+                optional_fields = [field for field in OPTIONAL_ITEM_FIELDS if field in data]
+                optional_set = ''.join(f', {field} = %s' for field in optional_fields)
+                
+                # Update the item
+                # completed_by follows the completed flag: set when it flips on, cleared when it flips off
+                cur.execute(f"""
+                    UPDATE shopping_list_items 
+                    SET name = %s, quantity = %s, category = %s, priority = %s, notes = %s, completed = %s,
+                        completed_by = CASE
+                            WHEN NOT %s THEN NULL
+                            WHEN NOT completed THEN %s
+                            ELSE completed_by
+                        END{optional_set}
+                    WHERE id = %s AND list_id = %s
+                    RETURNING {ITEM_COLUMNS}
+                """, (data['name'], data['quantity'], data['category'], data['priority'], data['notes'], data['completed'],
+                      data['completed'], user_id, *[data[field] for field in optional_fields], item_id, list_id))
+                
+                item = dict(cur.fetchone())
+                if 'tags' in data:
+                    item['tags'] = set_item_tags(cur, item_id, user_id, data['tags'])
+                record_item_history(cur, list_id, item_id, user_id, 'updated', before, item)
+                if item['assigned_to'] != before['assigned_to']:
+                    notify_item_assigned(cur, list_id, item, user_id)
+                if item['completed'] and not before['completed']:
+                    schedule_recurrence(cur, list_id, item, user_id)
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item updated successfully',
+                    'item': dict(item)
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Update item error: {e}")
+        return jsonify({'error': 'Failed to update item'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>/toggle', methods=['PUT'])
+@jwt_required()
+def toggle_list_item(list_id, item_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Verify list access (owner or shared with write permission for toggling)
+                # Note: Even read-only users should be able to toggle items (like in shared view)
+                cur.execute("""
+                    SELECT sl.id 
+                    FROM shopping_lists sl
+                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    WHERE sl.id = %s AND (
+                        sl.owner_id = %s OR 
+                        ls.id IS NOT NULL
+                    )
+                """, (user_id, list_id, user_id))
+                if not cur.fetchone():
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                # Toggle the item's completed status
+                cur.execute(f"""
+                    UPDATE shopping_list_items 
+                    SET completed = NOT completed,
+                        completed_by = CASE WHEN completed THEN NULL ELSE %s END,
+                        updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                    RETURNING {ITEM_COLUMNS}
+                """, (user_id, item_id, list_id))
+                
+                item = cur.fetchone()
+                if not item:
+                    return jsonify({'error': 'Item not found'}), 404
+                
+                record_item_history(cur, list_id, item_id, user_id, 'updated',
+                                    {'completed': not item['completed']}, {'completed': item['completed']})
+                if item['completed']:
+                    schedule_recurrence(cur, list_id, item, user_id)
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item toggled successfully',
+                    'item': {
+                        'id': item['id'],
+                        'name': item['name'],
+                        'completed': item['completed'],
+                        'completed_by': item['completed_by']
+                    }
+                }), 200
+                
+    except Exception as e:
+        print(f"Toggle item error: {e}")
+        return jsonify({'error': 'Failed to toggle item'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>', methods=['DELETE'])
+@jwt_required()
+def delete_list_item(list_id, item_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                # Move the item to the trash; it is purged after TRASH_RETENTION_DAYS
+                cur.execute("""
+                    UPDATE shopping_list_items 
+                    SET deleted_at = CURRENT_TIMESTAMP
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                    RETURNING id, name, quantity, category, priority, notes, completed
+                """, (item_id, list_id))
+                
+                item = cur.fetchone()
+                if not item:
+                    return jsonify({'error': 'Item not found'}), 404
+                
+                record_item_history(cur, list_id, item_id, user_id, 'deleted', before=item)
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Item deleted successfully',
+                    'item': {
+                        'id': item['id'],
+                        'name': item['name']
+                    }
+                }), 200
+                
+    except Exception as e:
+        print(f"Delete item error: {e}")
+        return jsonify({'error': 'Failed to delete item'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/clear-completed', methods=['POST'])
+@jwt_required()
+def clear_completed_items(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                # Completed items go to the trash like single deletes
+                cur.execute("""
+                    UPDATE shopping_list_items
+                    SET deleted_at = CURRENT_TIMESTAMP
+                    WHERE list_id = %s AND completed AND deleted_at IS NULL
+                    RETURNING id, name, quantity, category, priority, notes, completed
+                """, (list_id,))
+                
+                items = cur.fetchall()
+                for item in items:
+                    record_item_history(cur, list_id, item['id'], user_id, 'deleted', before=item)
+                
+                operation_id = None
+                if items:
+                    operation_id = record_item_operation(cur, list_id, user_id, 'clear_completed',
+                                                         {'item_ids': [item['id'] for item in items]})
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'{len(items)} completed items cleared',
+                    'cleared_count': len(items),
+                    'operation_id': operation_id
+                }), 200
+                
+    except Exception as e:
+        print(f"Clear completed items error: {e}")
+        return jsonify({'error': 'Failed to clear completed items'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/complete-category', methods=['POST'])
+@jwt_required()
+def complete_category_items(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = CategoryCompletionSchema()
+        data = schema.load(request.json or {})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND category = %s AND completed <> %s AND deleted_at IS NULL
+                    FOR UPDATE
+                """, (list_id, data['category'], data['completed']))
+                before_items = cur.fetchall()
+                
+                # Same completed_by rule as a single update
+                cur.execute(f"""
+                    UPDATE shopping_list_items
+                    SET completed = %s, completed_by = CASE WHEN %s THEN %s END
+                    WHERE id = ANY(%s)
+                    RETURNING {ITEM_COLUMNS}
+                """, (data['completed'], data['completed'], user_id, [item['id'] for item in before_items]))
+                items = {item['id']: dict(item) for item in cur.fetchall()}
+                
+                snapshot = []
+                for before in before_items:
+                    item = items[before['id']]
+                    record_item_history(cur, list_id, item['id'], user_id, 'updated', before, item)
+                    if item['completed']:
+                        schedule_recurrence(cur, list_id, item, user_id)
+                    snapshot.append({'id': item['id'], 'before': {
+                        'completed': before['completed'],
+                        'completed_by': before['completed_by'],
+                        'completed_at': before['completed_at'] and before['completed_at'].isoformat()
+                    }})
+                
+                # Undone like a bulk update
+                operation_id = None
+                if snapshot:
+                    operation_id = record_item_operation(cur, list_id, user_id, 'bulk_update', {'items': snapshot})
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'{len(items)} items updated',
+                    'updated_count': len(items),
+                    'operation_id': operation_id
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Complete category items error: {e}")
+        return jsonify({'error': 'Failed to update category items'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/trash', methods=['GET'])
+@jwt_required()
+def get_trashed_items(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not is_list_member(cur, list_id, user_id):
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}, deleted_at,
+                           deleted_at + %s AS purge_at
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND deleted_at IS NOT NULL
+                    ORDER BY deleted_at DESC
+                """, (TRASH_RETENTION, list_id))
+                
+                items = cur.fetchall()
+                
+                return jsonify({
+                    'items': [dict(item) for item in items]
+                })
+                
+    except Exception as e:
+        print(f"Get trashed items error: {e}")
+        return jsonify({'error': 'Failed to get trashed items'}), 500
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>/restore', methods=['POST'])
+@jwt_required()
+def restore_list_item(list_id, item_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Restoring needs the same access as editing
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                if item_quota_exceeded(cur, list_id):
+                    return item_quota_response(cur, list_id)
+                
+                cur.execute(f"""
+                    UPDATE shopping_list_items
+                    SET deleted_at = NULL
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NOT NULL
+                    RETURNING {ITEM_COLUMNS}
+                """, (item_id, list_id))
+                
+                item = cur.fetchone()
+                if not item:
+                    return jsonify({'error': 'Item not found in trash'}), 404
+                
+                record_item_history(cur, list_id, item_id, user_id, 'restored', after=item)
+                
+                conn.commit()
                 
                 return jsonify({
-                    'groceries': [dict(row) for row in groceries]
-                })
+                    'message': 'Item restored successfully',
+                    'item': dict(item)
+                }), 200
                 
     except Exception as e:
-        print(f"Get grocery memory error: {e}")
-        return jsonify({'error': 'Failed to get grocery memory'}), 500
+        print(f"Restore item error: {e}")
+        return jsonify({'error': 'Failed to restore item'}), 500
 
-@app.route('/api/groceries/frequent', methods=['GET'])
+@app.route('/api/lists/<int:list_id>/undo', methods=['POST'])
 @jwt_required()
-def get_frequent_groceries():
+def undo_item_operation(list_id):
     try:
         user_id = int(get_jwt_identity())
-        limit = int(request.args.get('limit', 8))
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                # Only the latest operation can be undone, so later changes are never reverted out of order
                 cur.execute("""
-                    SELECT name, category, priority, usage_count, last_used
-                    FROM grocery_memory 
-                    WHERE user_id = %s
-                    ORDER BY usage_count DESC, last_used DESC 
-                    LIMIT %s
-                """, (user_id, limit))
+                    SELECT id, user_id, operation, snapshot
+                    FROM item_operations
+                    WHERE list_id = %s AND undone_at IS NULL AND created_at >= CURRENT_TIMESTAMP - %s
+                    ORDER BY created_at DESC, id DESC
+                    LIMIT 1
+                    FOR UPDATE
+                """, (list_id, UNDO_WINDOW))
+                operation = cur.fetchone()
+                if not operation:
+                    return jsonify({'error': 'Nothing to undo'}), 404
+                
+                if operation['user_id'] != user_id and not can_manage_list(cur, list_id, user_id):
+                    return jsonify({'error': 'Only the user who made this change or a list admin can undo it'}), 403
+                
+                items = []
+                if operation['operation'] == 'bulk_update':
+                    for entry in operation['snapshot']['items']:
+                        restore = entry['before']
+                        cur.execute(f"""
+                            SELECT {ITEM_COLUMNS} FROM shopping_list_items
+                            WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                            FOR UPDATE
+                        """, (entry['id'], list_id))
+                        before = cur.fetchone()
+                        if not before:
+                            continue
+                        
+                        set_clause = ', '.join(f'{field} = %s' for field in restore)
+                        cur.execute(f"""
+                            UPDATE shopping_list_items
+                            SET {set_clause}
+                            WHERE id = %s
+                            RETURNING {ITEM_COLUMNS}
+                        """, (*restore.values(), entry['id']))
+                        item = dict(cur.fetchone())
+                        record_item_history(cur, list_id, item['id'], user_id, 'updated', before, item)
+                        items.append(item)
+                else:
+                    # Items added since the clear may have used the room the cleared ones left
+                    if item_quota_exceeded(cur, list_id, adding=len(operation['snapshot']['item_ids'])):
+                        conn.rollback()
+                        return item_quota_response(cur, list_id)
+                    
+                    cur.execute(f"""
+                        UPDATE shopping_list_items
+                        SET deleted_at = NULL
+                        WHERE id = ANY(%s) AND list_id = %s AND deleted_at IS NOT NULL
+                        RETURNING {ITEM_COLUMNS}
+                    """, (operation['snapshot']['item_ids'], list_id))
+                    for item in cur.fetchall():
+                        record_item_history(cur, list_id, item['id'], user_id, 'restored', after=item)
+                        items.append(dict(item))
+                
+                cur.execute(
+                    "UPDATE item_operations SET undone_at = CURRENT_TIMESTAMP WHERE id = %s",
+                    (operation['id'],)
+                )
                 
-                groceries = cur.fetchall()
+                conn.commit()
                 
                 return jsonify({
-                    'groceries': [dict(row) for row in groceries]
-                })
+                    'message': 'Change undone',
+                    'operation': operation['operation'],
+                    'items': items
+                }), 200
                 
     except Exception as e:
-        print(f"Get frequent groceries error: {e}")
-        return jsonify({'error': 'Failed to get frequent groceries'}), 500
+        print(f"Undo item operation error: {e}")
+        return jsonify({'error': 'Failed to undo change'}), 500
 
-@app.route('/api/groceries/stats', methods=['GET'])
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>/image', methods=['POST'])
 @jwt_required()
-def get_grocery_stats():
+def upload_item_image(list_id, item_id):
     try:
         user_id = int(get_jwt_identity())
         
+        image = request.files.get('image')
+        if not image:
+            return jsonify({'error': 'An image file is required'}), 400
+        
+        # Read one byte past the limit so oversized uploads are detected without buffering them whole
+        data = image.stream.read(IMAGE_MAX_BYTES + 1)
+        if len(data) > IMAGE_MAX_BYTES:
+            return jsonify({'error': f'Image must be at most {IMAGE_MAX_BYTES // (1024 * 1024)} MB'}), 413
+        if not data:
+            return jsonify({'error': 'Image file is empty'}), 400
+        
+        # The type comes from the file's contents; the declared Content-Type is not trusted
+        extension = sniff_image_extension(data)
+        if not extension:
+            return jsonify({'error': f"Unsupported image type; allowed: {', '.join(IMAGE_CONTENT_TYPES)}"}), 415
+        
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
                 cur.execute("""
-                    SELECT 
-                        COUNT(*) as total_items,
-                        COALESCE(SUM(usage_count), 0) as total_usage,
-                        COALESCE(AVG(usage_count), 0) as avg_usage
-                    FROM grocery_memory 
-                    WHERE user_id = %s
-                """, (user_id,))
+                    SELECT image_url FROM shopping_list_items
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                    FOR UPDATE
+                """, (item_id, list_id))
+                before = cur.fetchone()
+                if not before:
+                    return jsonify({'error': 'Item not found'}), 404
                 
-                stats = cur.fetchone()
+                image_url = image_storage.save(data, extension)
+                
+                cur.execute(f"""
+                    UPDATE shopping_list_items
+                    SET image_url = %s
+                    WHERE id = %s AND list_id = %s
+                    RETURNING {ITEM_COLUMNS}
+                """, (image_url, item_id, list_id))
+                item = cur.fetchone()
+                
+                record_item_history(cur, list_id, item_id, user_id, 'updated',
+                                    before, {'image_url': item['image_url']})
+                
+                conn.commit()
                 
                 return jsonify({
-                    'stats': {
-                        'totalItems': stats['total_items'],
-                        'totalUsage': stats['total_usage'],
-                        'averageUsage': round(float(stats['avg_usage']), 1)
-                    }
-                })
+                    'message': 'Image uploaded successfully',
+                    'item': dict(item)
+                }), 200
                 
+    except RequestEntityTooLarge as e:
+        # Raised while parsing the form once the body passes MAX_CONTENT_LENGTH
+        return request_too_large(e)
     except Exception as e:
-        print(f"Get grocery stats error: {e}")
-        return jsonify({'error': 'Failed to get grocery statistics'}), 500
+        print(f"Upload item image error: {e}")
+        return jsonify({'error': 'Failed to upload image'}), 500
 
-# Shopping list routes
-@app.route('/api/lists', methods=['GET'])
+@app.route('/api/uploads/<path:filename>', methods=['GET'])
+def get_uploaded_image(filename):
+    # Only locally stored images are served by the API; other backends serve their own URLs
+    if not isinstance(image_storage, LocalImageStorage):
+        return jsonify({'error': 'Not found'}), 404
+    response = send_from_directory(os.path.abspath(image_storage.directory), filename)
+    # Browsers must not reinterpret an upload as anything but the image type its extension names
+    response.headers['X-Content-Type-Options'] = 'nosniff'
+    return response
+
+@app.route('/api/lists/<int:list_id>/items/<int:item_id>/history', methods=['GET'])
 @jwt_required()
-def get_shopping_lists():
+def get_item_history(list_id, item_id):
     try:
         user_id = int(get_jwt_identity())
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Get owned lists
+                # Verify list access (owner or any accepted share)
                 cur.execute("""
-                    SELECT 
-                        sl.id, sl.name, sl.is_shared, sl.created_at, sl.updated_at,
-                        COUNT(sli.id) as item_count,
-                        COUNT(CASE WHEN sli.completed = true THEN 1 END) as completed_count,
-                        COALESCE((sl.id = u.default_list_id), false) as is_default,
-                        'owner' as role,
-                        u.username as owner_username
-                    FROM shopping_lists sl
-                    LEFT JOIN shopping_list_items sli ON sl.id = sli.list_id
-                    LEFT JOIN users u ON u.id = sl.owner_id
-                    WHERE sl.owner_id = %s
-                    GROUP BY sl.id, u.default_list_id, u.username
-                    
-                    UNION
-                    
-                    SELECT 
-                        sl.id, sl.name, sl.is_shared, sl.created_at, sl.updated_at,
-                        COUNT(sli.id) as item_count,
-                        COUNT(CASE WHEN sli.completed = true THEN 1 END) as completed_count,
-                        false as is_default,
-                        ls.permission as role,
-                        u.username as owner_username
+                    SELECT sl.id 
                     FROM shopping_lists sl
-                    LEFT JOIN shopping_list_items sli ON sl.id = sli.list_id
-                    LEFT JOIN users u ON u.id = sl.owner_id
-                    INNER JOIN list_shares ls ON ls.list_id = sl.id
-                    WHERE ls.user_id = %s AND ls.status = 'accepted'
-                    GROUP BY sl.id, ls.permission, u.username
-                    
-                    ORDER BY updated_at DESC
-                """, (user_id, user_id))
+                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    WHERE sl.id = %s AND (
+                        sl.owner_id = %s OR 
+                        ls.id IS NOT NULL
+                    )
+                """, (user_id, list_id, user_id))
+                if not cur.fetchone():
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
                 
-                lists = cur.fetchall()
+                cur.execute("""
+                    SELECT ih.id, ih.change_type, ih.changes, ih.created_at,
+                           ih.user_id, u.username
+                    FROM item_history ih
+                    LEFT JOIN users u ON u.id = ih.user_id
+                    WHERE ih.list_id = %s AND ih.item_id = %s
+                    ORDER BY ih.created_at DESC, ih.id DESC
+                """, (list_id, item_id))
+                
+                history = cur.fetchall()
                 
                 return jsonify({
-                    'lists': [dict(row) for row in lists]
+                    'history': [dict(entry) for entry in history]
                 })
                 
     except Exception as e:
-        print(f"Get shopping lists error: {e}")
-        return jsonify({'error': 'Failed to get shopping lists'}), 500
+        print(f"Get item history error: {e}")
+        return jsonify({'error': 'Failed to get item history'}), 500
 
-@app.route('/api/lists', methods=['POST'])
+@app.route('/api/lists/<int:list_id>/activity', methods=['GET'])
 @jwt_required()
-def create_shopping_list():
+def get_list_activity(list_id):
     try:
         user_id = int(get_jwt_identity())
-        schema = ShoppingListSchema()
-        data = schema.load(request.json or {})
         
-        name = data['name']
+        try:
+            limit, offset = parse_pagination(default_limit=50)
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not is_list_member(cur, list_id, user_id):
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                try:
+                    tz = requested_timezone(cur, user_id)
+                except ValueError as e:
+                    return jsonify({'error': str(e)}), 400
+                
+                # Item changes, collaborators joining and list-level events, newest first
                 cur.execute("""
-                    INSERT INTO shopping_lists (name, owner_id)
-                    VALUES (%s, %s)
-                    RETURNING id, name, is_shared, created_at, updated_at
-                """, (name, user_id))
+                    SELECT * FROM (
+                        SELECT
+                            CASE
+                                WHEN ih.change_type = 'created' THEN 'item_added'
+                                WHEN ih.change_type = 'deleted' THEN 'item_deleted'
+                                WHEN ih.change_type = 'restored' THEN 'item_restored'
+                                WHEN ih.changes->'completed'->>'new' = 'true' THEN 'item_completed'
+                                WHEN ih.changes->'completed'->>'new' = 'false' THEN 'item_uncompleted'
+                                ELSE 'item_updated'
+                            END AS event_type,
+                            ih.item_id,
+                            COALESCE(sli.name, ih.changes->'name'->>'old', ih.changes->'name'->>'new') AS item_name,
+                            ih.changes AS details,
+                            ih.user_id, u.username, ih.created_at
+                        FROM item_history ih
+                        LEFT JOIN shopping_list_items sli ON sli.id = ih.item_id
+                        LEFT JOIN users u ON u.id = ih.user_id
+                        WHERE ih.list_id = %s
+                        
+                        UNION ALL
+                        
+                        SELECT 'list_shared', NULL, NULL,
+                               jsonb_build_object('permission', ls.permission),
+                               ls.user_id, u.username, ls.shared_at
+                        FROM list_shares ls
+                        JOIN users u ON u.id = ls.user_id
+                        WHERE ls.list_id = %s AND ls.status = 'accepted'
+                        
+                        UNION ALL
+                        
+                        SELECT le.event_type, NULL, NULL, le.data,
+                               le.user_id, u.username, le.created_at
+                        FROM list_events le
+                        LEFT JOIN users u ON u.id = le.user_id
+                        WHERE le.list_id = %s
+                    ) AS activity
+                    ORDER BY created_at DESC
+                    LIMIT %s OFFSET %s
+                """, (list_id, list_id, list_id, limit, offset))
+                
+                activity = [dict(event) for event in cur.fetchall()]
+                if tz:
+                    for event in activity:
+                        event['created_at_local'] = localize_timestamp(event['created_at'], tz)
+                
+                return jsonify({
+                    'activity': activity,
+                    'limit': limit,
+                    'offset': offset
+                })
+                
+    except Exception as e:
+        print(f"Get list activity error: {e}")
+        return jsonify({'error': 'Failed to get list activity'}), 500
+
+@app.route('/api/lists/<int:list_id>', methods=['PUT', 'PATCH'])
+@jwt_required()
+def update_shopping_list(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ShoppingListSchema()
+        # Partial load: only the fields that were sent are changed
+        data = schema.load(request.json, partial=True)
+        
+        fields_to_update = [field for field in UPDATABLE_LIST_FIELDS if field in data]
+        if not fields_to_update:
+            return jsonify({'error': 'No fields to update'}), 400
+        
+        set_clause = ', '.join(f'{field} = %s' for field in fields_to_update)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_manage_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute(
+                    "SELECT name FROM shopping_lists WHERE id = %s FOR UPDATE",
+                    (list_id,)
+                )
+                before = cur.fetchone()
+                
+                # Update list details
+                cur.execute(f"""
+                    UPDATE shopping_lists 
+                    SET {set_clause}, updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s
+                    RETURNING id, name, color, icon, is_shared, created_at, updated_at
+                """, (*[data[field] for field in fields_to_update], list_id))
                 
                 list_data = cur.fetchone()
+                if list_data['name'] != before['name']:
+                    record_list_event(cur, list_id, user_id, 'list_renamed',
+                                      {'old': before['name'], 'new': list_data['name']})
+                queue_webhook_event(cur, list_id, 'list.updated', {'user_id': user_id, 'list': dict(list_data)})
+                
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Shopping list created',
+                    'message': 'Shopping list updated',
                     'list': dict(list_data)
-                }), 201
+                }), 200
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
     except Exception as e:
-        print(f"Create shopping list error: {e}")
-        return jsonify({'error': 'Failed to create shopping list'}), 500
+        print(f"Update shopping list error: {e}")
+        return jsonify({'error': 'Failed to update shopping list'}), 500
 
-@app.route('/api/lists/<int:list_id>', methods=['GET'])
+@app.route('/api/lists/<int:list_id>', methods=['DELETE'])
 @jwt_required()
-def get_shopping_list(list_id):
+def delete_shopping_list(list_id):
     try:
         user_id = int(get_jwt_identity())
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Get list info and user's permission (check both owned and shared lists)
-                cur.execute("""
-                    SELECT sl.id, sl.name, sl.is_shared, sl.created_at, sl.updated_at, 
-                           CASE 
-                               WHEN sl.owner_id = %s THEN 'admin'
-                               ELSE ls.permission
-                           END as user_permission,
-                           CASE WHEN sl.owner_id = %s THEN TRUE ELSE FALSE END as is_owner
-                    FROM shopping_lists sl
-                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
-                    WHERE sl.id = %s AND (sl.owner_id = %s OR ls.id IS NOT NULL)
-                """, (user_id, user_id, user_id, list_id, user_id))
+                if not can_manage_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
                 
-                list_data = cur.fetchone()
-                if not list_data:
-                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                list_data = delete_list(cur, list_id, user_id)
                 
-                # Get list items
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'Shopping list "{list_data["name"]}" deleted successfully'
+                }), 200
+                
+    except Exception as e:
+        print(f"Delete shopping list error: {e}")
+        return jsonify({'error': 'Failed to delete shopping list'}), 500
+
+@app.route('/api/users/me/sessions', methods=['GET'])
+@jwt_required()
+def get_sessions():
+    try:
+        user_id = int(get_jwt_identity())
+        current_jti = get_jwt()['jti']
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 cur.execute("""
-                    SELECT id, name, quantity, category, priority, notes, completed, created_at, updated_at
-                    FROM shopping_list_items
-                    WHERE list_id = %s
-                    ORDER BY created_at DESC
-                """, (list_id,))
+                    SELECT id, ip_address, user_agent, created_at, last_seen_at, expires_at,
+                           (jti = %s) AS is_current
+                    FROM user_sessions
+                    WHERE user_id = %s AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+                    ORDER BY last_seen_at DESC
+                """, (current_jti, user_id))
                 
-                items = cur.fetchall()
+                sessions = cur.fetchall()
                 
                 return jsonify({
-                    'list': {
-                        **dict(list_data),
-                        'items': [dict(item) for item in items]
-                    }
+                    'sessions': [dict(session) for session in sessions]
                 })
                 
     except Exception as e:
-        print(f"Get shopping list error: {e}")
-        return jsonify({'error': 'Failed to get shopping list'}), 500
+        print(f"Get sessions error: {e}")
+        return jsonify({'error': 'Failed to get sessions'}), 500
 
-@app.route('/api/lists/<int:list_id>/items', methods=['POST'])
+@app.route('/api/users/me/sessions/<int:session_id>', methods=['DELETE'])
 @jwt_required()
-def add_list_item(list_id):
+def revoke_session(session_id):
     try:
         user_id = int(get_jwt_identity())
-        schema = ShoppingListItemSchema()
-        data = schema.load(request.json)
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify list access (owner or shared with write permission)
                 cur.execute("""
-                    SELECT sl.id 
-                    FROM shopping_lists sl
-                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
-                    WHERE sl.id = %s AND (
-                        sl.owner_id = %s OR 
-                        (ls.id IS NOT NULL AND ls.permission IN ('write', 'admin'))
-                    )
-                """, (user_id, list_id, user_id))
+                    UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+                    WHERE id = %s AND user_id = %s AND revoked_at IS NULL
+                    RETURNING id
+                """, (session_id, user_id))
+                
                 if not cur.fetchone():
-                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                    return jsonify({'error': 'Session not found'}), 404
+                
+                conn.commit()
                 
-                # Add item
+                return jsonify({'message': 'Session revoked'}), 200
+                
+    except Exception as e:
+        print(f"Revoke session error: {e}")
+        return jsonify({'error': 'Failed to revoke session'}), 500
+
+@app.route('/api/users/me/sessions', methods=['DELETE'])
+@jwt_required()
+def revoke_all_sessions():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Log out everywhere, including the session making this request
                 cur.execute("""
-                    INSERT INTO shopping_list_items (list_id, name, quantity, category, priority, notes)
-                    VALUES (%s, %s, %s, %s, %s, %s)
-                    RETURNING id, name, quantity, category, priority, notes, completed, created_at, updated_at
-                """, (list_id, data['name'], data['quantity'], data['category'], data['priority'], data['notes']))
+                    UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+                    WHERE user_id = %s AND revoked_at IS NULL
+                """, (user_id,))
+                revoked_count = cur.rowcount
                 
-                item = cur.fetchone()
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'All sessions revoked',
+                    'revoked_count': revoked_count
+                }), 200
+                
+    except Exception as e:
+        print(f"Revoke all sessions error: {e}")
+        return jsonify({'error': 'Failed to revoke sessions'}), 500
+
+# Sections of the personal data export, each a query taking the user id; other users' data is left out
+EXPORT_SECTIONS = {
+    'lists': """
+        SELECT id, name, color, icon, is_shared, group_id, created_at, updated_at
+        FROM shopping_lists WHERE owner_id = %s ORDER BY id
+    """,
+    'items': """
+        SELECT sli.id, sli.list_id, sli.name, sli.quantity, sli.amount, sli.category, sli.priority, sli.notes,
+               sli.completed, sli.completed_at, sli.recurring, sli.recur_interval_days, sli.due_date, sli.image_url, sli.barcode,
+               sli.created_at, sli.updated_at, sli.deleted_at,
+               ARRAY(
+                   SELECT t.name FROM shopping_list_item_tags it
+                   JOIN item_tags t ON t.id = it.tag_id
+                   WHERE it.item_id = sli.id
+                   ORDER BY t.name
+               ) AS tags
+        FROM shopping_list_items sli
+        JOIN shopping_lists sl ON sl.id = sli.list_id
+        WHERE sl.owner_id = %s
+        ORDER BY sli.list_id, sli.id
+    """,
+    'list_groups': "SELECT id, name, created_at, updated_at FROM list_groups WHERE owner_id = %s ORDER BY id",
+    # Lists shared with the user; their contents belong to the owners
+    'shares': """
+        SELECT ls.list_id, sl.name AS list_name, ls.permission, ls.status, ls.shared_at
+        FROM list_shares ls
+        JOIN shopping_lists sl ON sl.id = ls.list_id
+        WHERE ls.user_id = %s
+        ORDER BY ls.shared_at
+    """,
+    'notifications': """
+        SELECT id, type, title, message, data, is_read, created_at
+        FROM notifications WHERE user_id = %s ORDER BY created_at
+    """,
+    'grocery_memory': """
+        SELECT name, category, priority, usage_count, last_used, created_at
+        FROM grocery_memory WHERE user_id = %s ORDER BY name
+    """,
+    'favorites': f"SELECT {FAVORITE_COLUMNS} FROM favorite_items WHERE user_id = %s ORDER BY name",
+    'category_colors': "SELECT category, color FROM category_colors WHERE user_id = %s ORDER BY category",
+    # Secrets are never exported
+    'webhooks': "SELECT id, url, events, enabled, created_at, updated_at FROM webhooks WHERE user_id = %s ORDER BY id"
+}
+
+def export_json(value):
+    return json.dumps(value, default=lambda v: v.isoformat() if hasattr(v, 'isoformat') else str(v))
+
+@app.route('/api/users/me/export', methods=['GET'])
+@jwt_required()
+def export_current_user():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT id, username, email, email_verified, timezone, locale, digest_enabled, created_at, updated_at
+                    FROM users WHERE id = %s
+                """, (user_id,))
+                user = cur.fetchone()
+                
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+        
+        def generate():
+            # Written one row at a time so large accounts aren't built up in memory
+            yield (f'{{"version": {EXPORT_VERSION}, "exported_at": {export_json(datetime.utcnow().isoformat() + "Z")}, '
+                   f'"user": {export_json(user)}')
+            with get_db_connection() as conn:
+                with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                    for section, query in EXPORT_SECTIONS.items():
+                        cur.execute(query, (user_id,))
+                        yield f', "{section}": ['
+                        for index, row in enumerate(cur):
+                            yield (', ' if index else '') + export_json(row)
+                        yield ']'
+            yield '}\n'
+        
+        filename = f"shopping-list-export-{datetime.utcnow().strftime('%Y%m%d')}.json"
+        return app.response_class(stream_with_context(generate()), mimetype='application/json', headers={
+            'Content-Disposition': f'attachment; filename="{filename}"'
+        })
+        
+    except Exception as e:
+        print(f"Export user data error: {e}")
+        return jsonify({'error': 'Failed to export user data'}), 500
+
+def utc_naive(value):
+    """Store aware timestamps as naive UTC like the rest of the schema"""
+    if value is not None and value.tzinfo is not None:
+        return value.astimezone(timezone.utc).replace(tzinfo=None)
+    return value
+
+@app.route('/api/lists/import', methods=['POST'])
+@jwt_required()
+def import_shopping_list():
+    try:
+        user_id = int(get_jwt_identity())
+        preserve = request.args.get('preserve', '').lower() == 'true'
+        schema = ListImportSchema()
+        data = schema.load(request.json or {})
+        
+        if 'list_id' in data:
+            source = next((l for l in data['lists'] if l.get('id') == data['list_id']), None)
+            if source is None:
+                raise ValidationError({'list_id': ['No list with this id in the export.']})
+        elif len(data['lists']) == 1:
+            source = data['lists'][0]
+        else:
+            raise ValidationError({'list_id': ['Required when the export holds more than one list.']})
+        
+        list_fields = ShoppingListSchema(only=('name', 'color', 'icon'), unknown=EXCLUDE).load(
+            {key: source[key] for key in ('name', 'color', 'icon') if key in source})
+        list_fields['name'] = data.get('name', list_fields['name'])
+        
+        # Trashed items are left behind; errors are reported by index into the export's items
+        item_schema = ListImportItemSchema()
+        items = []
+        for index, raw in enumerate(data['items']):
+            if not isinstance(raw, dict) or raw.get('list_id') != source.get('id') or raw.get('deleted_at'):
+                continue
+            try:
+                items.append(item_schema.load(raw))
+            except ValidationError as e:
+                raise ValidationError({'items': {index: e.messages}})
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("SELECT is_guest FROM users WHERE id = %s", (user_id,))
+                max_lists = GUEST_MAX_LISTS if cur.fetchone()['is_guest'] else MAX_LISTS_PER_USER
+                cur.execute("SELECT COUNT(*) AS count FROM shopping_lists WHERE owner_id = %s", (user_id,))
+                if cur.fetchone()['count'] >= max_lists:
+                    return jsonify({'error': f'You can own at most {max_lists} shopping lists'}), 403
                 
-                # Update grocery memory
                 cur.execute("""
-                    INSERT INTO grocery_memory (user_id, name, category, priority, usage_count, last_used)
-                    VALUES (%s, %s, %s, %s, 1, CURRENT_TIMESTAMP)
-                    ON CONFLICT (user_id, name) 
-                    DO UPDATE SET 
-                        category = EXCLUDED.category,
-                        priority = EXCLUDED.priority,
-                        usage_count = grocery_memory.usage_count + 1,
-                        last_used = CURRENT_TIMESTAMP
-                """, (user_id, data['name'], data['category'], data['priority']))
+                    INSERT INTO shopping_lists (name, owner_id, color, icon)
+                    VALUES (%s, %s, %s, %s)
+                    RETURNING id, name, color, icon, is_shared, created_at, updated_at
+                """, (list_fields['name'], user_id, list_fields.get('color'), list_fields.get('icon')))
+                new_list = cur.fetchone()
+                list_id = new_list['id']
+                
+                if item_quota_exceeded(cur, list_id, adding=len(items)):
+                    conn.rollback()
+                    return item_quota_response(cur, list_id)
+                
+                for item in items:
+                    completed = preserve and item['completed']
+                    cur.execute(f"""
+                        INSERT INTO shopping_list_items (list_id, name, quantity, amount, category, priority, notes, created_by,
+                                                         completed, completed_by, completed_at, recurring, recur_interval_days,
+                                                         due_date, image_url, barcode, created_at, updated_at)
+                        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s,
+                                COALESCE(%s, CURRENT_TIMESTAMP), COALESCE(%s, CURRENT_TIMESTAMP))
+                        RETURNING {ITEM_COLUMNS}
+                    """, (list_id, item['name'], item['quantity'], item.get('amount'), item['category'], item['priority'],
+                          item['notes'], user_id, completed, user_id if completed else None,
+                          utc_naive(item.get('completed_at')) if completed else None,
+                          item.get('recurring', False), item.get('recur_interval_days'), item.get('due_date'),
+                          item.get('image_url'), item.get('barcode'),
+                          utc_naive(item.get('created_at')) if preserve else None,
+                          utc_naive(item.get('updated_at')) if preserve else None))
+                    
+                    created = dict(cur.fetchone())
+                    if item.get('tags'):
+                        created['tags'] = set_item_tags(cur, created['id'], user_id, item['tags'])
+                    record_item_history(cur, list_id, created['id'], user_id, 'created', after=created)
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Item added to shopping list',
-                    'item': dict(item)
+                    'message': 'Shopping list imported',
+                    'list': dict(new_list),
+                    'imported_count': len(items)
                 }), 201
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
     except Exception as e:
-        print(f"Add item error: {e}")
-        return jsonify({'error': 'Failed to add item to shopping list'}), 500
+        print(f"Import shopping list error: {e}")
+        return jsonify({'error': 'Failed to import shopping list'}), 500
 
-@app.route('/api/lists/<int:list_id>/items/<int:item_id>', methods=['PUT'])
+@app.route('/api/lists/<int:list_id>/merge', methods=['POST'])
 @jwt_required()
-def update_list_item(list_id, item_id):
+def merge_shopping_lists(list_id):
     try:
         user_id = int(get_jwt_identity())
-        schema = ShoppingListItemSchema()
+        schema = ListMergeSchema()
         data = schema.load(request.json)
+        source_list_id = data['source_list_id']
+        
+        if source_list_id == list_id:
+            return jsonify({'error': 'Cannot merge a list into itself'}), 400
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify list access (owner or shared with write permission)
-                cur.execute("""
-                    SELECT sl.id 
-                    FROM shopping_lists sl
-                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
-                    WHERE sl.id = %s AND (
-                        sl.owner_id = %s OR 
-                        (ls.id IS NOT NULL AND ls.permission IN ('write', 'admin'))
-                    )
-                """, (user_id, list_id, user_id))
-                if not cur.fetchone():
-                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                if not can_write_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                if not is_list_member(cur, source_list_id, user_id):
+                    return jsonify({'error': 'Source list not found or access denied'}), 404
                 
-                # Update the item
-                cur.execute("""
-                    UPDATE shopping_list_items 
-                    SET name = %s, quantity = %s, category = %s, priority = %s, notes = %s, completed = %s
-                    WHERE id = %s AND list_id = %s
-                    RETURNING id, name, quantity, category, priority, notes, completed, created_at, updated_at
-                """, (data['name'], data['quantity'], data['category'], data['priority'], data['notes'], data['completed'], item_id, list_id))
+                if data['delete_source'] and not can_manage_list(cur, source_list_id, user_id):
+                    return jsonify({'error': 'Only the owner or an admin can delete the source list'}), 403
                 
-                item = cur.fetchone()
-                if not item:
-                    return jsonify({'error': 'Item not found'}), 404
+                cur.execute("""
+                    SELECT name, quantity, amount, category, priority, notes, completed
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND deleted_at IS NULL
+                    ORDER BY created_at
+                """, (source_list_id,))
+                source_items = cur.fetchall()
+                
+                copied_count = 0
+                merged_count = 0
+                
+                for source_item in source_items:
+                    if data['dedupe']:
+                        # Fold into an existing target item with the same name and category
+                        cur.execute("""
+                            UPDATE shopping_list_items
+                            SET quantity = quantity + %s
+                            WHERE id = (
+                                SELECT id FROM shopping_list_items
+                                WHERE list_id = %s AND LOWER(name) = LOWER(%s) AND category = %s AND deleted_at IS NULL
+                                ORDER BY completed, created_at
+                                LIMIT 1
+                            )
+                            RETURNING id, quantity
+                        """, (source_item['quantity'], list_id, source_item['name'], source_item['category']))
+                        merged = cur.fetchone()
+                        if merged:
+                            record_item_history(cur, list_id, merged['id'], user_id, 'updated',
+                                                {'quantity': merged['quantity'] - source_item['quantity']},
+                                                {'quantity': merged['quantity']})
+                            merged_count += 1
+                            continue
+                    
+                    cur.execute(f"""
+                        INSERT INTO shopping_list_items (list_id, name, quantity, amount, category, priority, notes, completed,
+                                                         created_by)
+                        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+                        RETURNING {ITEM_COLUMNS}
+                    """, (list_id, source_item['name'], source_item['quantity'], source_item['amount'], source_item['category'],
+                          source_item['priority'], source_item['notes'], source_item['completed'], user_id))
+                    item = cur.fetchone()
+                    record_item_history(cur, list_id, item['id'], user_id, 'created', after=item)
+                    copied_count += 1
+                
+                # Dedupe makes the number of copies unknown up front, so check once they are in
+                if copied_count and item_quota_exceeded(cur, list_id, 0):
+                    conn.rollback()
+                    return item_quota_response(cur, list_id)
+                
+                if data['delete_source']:
+                    delete_list(cur, source_list_id, user_id)
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Item updated successfully',
-                    'item': dict(item)
+                    'message': 'Shopping lists merged successfully',
+                    'copied_count': copied_count,
+                    'merged_count': merged_count,
+                    'source_deleted': data['delete_source']
                 }), 200
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
     except Exception as e:
-        print(f"Update item error: {e}")
-        return jsonify({'error': 'Failed to update item'}), 500
+        print(f"Merge shopping lists error: {e}")
+        return jsonify({'error': 'Failed to merge shopping lists'}), 500
 
-@app.route('/api/lists/<int:list_id>/items/<int:item_id>/toggle', methods=['PUT'])
+@app.route('/api/lists/<int:list_id>/group', methods=['PUT'])
 @jwt_required()
-def toggle_list_item(list_id, item_id):
+def set_list_group(list_id):
     try:
         user_id = int(get_jwt_identity())
+        schema = ListGroupAssignmentSchema()
+        data = schema.load(request.json)
+        group_id = data['group_id']
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify list access (owner or shared with write permission for toggling)
-                # Note: Even read-only users should be able to toggle items (like in shared view)
-                cur.execute("""
-                    SELECT sl.id 
-                    FROM shopping_lists sl
-                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
-                    WHERE sl.id = %s AND (
-                        sl.owner_id = %s OR 
-                        ls.id IS NOT NULL
+                if group_id is not None:
+                    cur.execute(
+                        "SELECT id FROM list_groups WHERE id = %s AND owner_id = %s",
+                        (group_id, user_id)
                     )
-                """, (user_id, list_id, user_id))
-                if not cur.fetchone():
-                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                    if not cur.fetchone():
+                        return jsonify({'error': 'List group not found'}), 404
                 
-                # Toggle the item's completed status
                 cur.execute("""
-                    UPDATE shopping_list_items 
-                    SET completed = NOT completed, updated_at = CURRENT_TIMESTAMP
-                    WHERE id = %s AND list_id = %s
-                    RETURNING id, name, completed
-                """, (item_id, list_id))
+                    UPDATE shopping_lists SET group_id = %s
+                    WHERE id = %s AND owner_id = %s
+                    RETURNING id, name, group_id
+                """, (group_id, list_id, user_id))
                 
-                item = cur.fetchone()
-                if not item:
-                    return jsonify({'error': 'Item not found'}), 404
+                list_data = cur.fetchone()
+                if not list_data:
+                    return jsonify({'error': 'Shopping list not found or not owned by user'}), 404
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Item toggled successfully',
-                    'item': {
-                        'id': item['id'],
-                        'name': item['name'],
-                        'completed': item['completed']
-                    }
+                    'message': 'List group updated',
+                    'list': dict(list_data)
                 }), 200
                 
+    except ValidationError as e:
+        return validation_error_response(e)
     except Exception as e:
-        print(f"Toggle item error: {e}")
-        return jsonify({'error': 'Failed to toggle item'}), 500
+        print(f"Set list group error: {e}")
+        return jsonify({'error': 'Failed to update list group'}), 500
 
-@app.route('/api/lists/<int:list_id>/items/<int:item_id>', methods=['DELETE'])
+# List group routes
+@app.route('/api/list-groups', methods=['GET'])
 @jwt_required()
-def delete_list_item(list_id, item_id):
+def get_list_groups():
     try:
         user_id = int(get_jwt_identity())
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify list access (owner or shared with write permission)
                 cur.execute("""
-                    SELECT sl.id 
-                    FROM shopping_lists sl
-                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
-                    WHERE sl.id = %s AND (
-                        sl.owner_id = %s OR 
-                        (ls.id IS NOT NULL AND ls.permission IN ('write', 'admin'))
-                    )
-                """, (user_id, list_id, user_id))
-                if not cur.fetchone():
-                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                    SELECT lg.id, lg.name, lg.created_at, lg.updated_at,
+                           COUNT(sl.id) as list_count
+                    FROM list_groups lg
+                    LEFT JOIN shopping_lists sl ON sl.group_id = lg.id
+                    WHERE lg.owner_id = %s
+                    GROUP BY lg.id
+                    ORDER BY lg.name
+                """, (user_id,))
+                
+                groups = cur.fetchall()
+                
+                return jsonify({
+                    'groups': [dict(group) for group in groups]
+                })
                 
-                # Delete the item
+    except Exception as e:
+        print(f"Get list groups error: {e}")
+        return jsonify({'error': 'Failed to get list groups'}), 500
+
+@app.route('/api/list-groups', methods=['POST'])
+@jwt_required()
+def create_list_group():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ListGroupSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 cur.execute("""
-                    DELETE FROM shopping_list_items 
-                    WHERE id = %s AND list_id = %s
-                    RETURNING id, name
-                """, (item_id, list_id))
+                    INSERT INTO list_groups (owner_id, name)
+                    VALUES (%s, %s)
+                    ON CONFLICT (owner_id, name) DO NOTHING
+                    RETURNING id, name, created_at, updated_at
+                """, (user_id, data['name'].strip()))
                 
-                item = cur.fetchone()
-                if not item:
-                    return jsonify({'error': 'Item not found'}), 404
+                group = cur.fetchone()
+                if not group:
+                    return jsonify({'error': 'A list group with this name already exists'}), 409
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Item deleted successfully',
-                    'item': {
-                        'id': item['id'],
-                        'name': item['name']
-                    }
-                }), 200
+                    'message': 'List group created',
+                    'group': dict(group)
+                }), 201
                 
+    except ValidationError as e:
+        return validation_error_response(e)
     except Exception as e:
-        print(f"Delete item error: {e}")
-        return jsonify({'error': 'Failed to delete item'}), 500
+        print(f"Create list group error: {e}")
+        return jsonify({'error': 'Failed to create list group'}), 500
 
-@app.route('/api/lists/<int:list_id>', methods=['PUT'])
+@app.route('/api/list-groups/<int:group_id>', methods=['PUT'])
 @jwt_required()
-def update_shopping_list(list_id):
+def update_list_group(group_id):
     try:
         user_id = int(get_jwt_identity())
-        schema = ShoppingListSchema()
+        schema = ListGroupSchema()
         data = schema.load(request.json)
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Update list name
                 cur.execute("""
-                    UPDATE shopping_lists 
-                    SET name = %s, updated_at = CURRENT_TIMESTAMP
+                    UPDATE list_groups SET name = %s
                     WHERE id = %s AND owner_id = %s
-                    RETURNING id, name, is_shared, created_at, updated_at
-                """, (data['name'], list_id, user_id))
+                    RETURNING id, name, created_at, updated_at
+                """, (data['name'].strip(), group_id, user_id))
                 
-                list_data = cur.fetchone()
-                if not list_data:
-                    return jsonify({'error': 'Shopping list not found'}), 404
+                group = cur.fetchone()
+                if not group:
+                    return jsonify({'error': 'List group not found'}), 404
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': 'Shopping list updated',
-                    'list': dict(list_data)
+                    'message': 'List group updated',
+                    'group': dict(group)
                 }), 200
                 
     except ValidationError as e:
-        return jsonify({'error': 'Validation error', 'details': e.messages}), 400
+        return validation_error_response(e)
+    except psycopg2.IntegrityError:
+        return jsonify({'error': 'A list group with this name already exists'}), 409
     except Exception as e:
-        print(f"Update shopping list error: {e}")
-        return jsonify({'error': 'Failed to update shopping list'}), 500
+        print(f"Update list group error: {e}")
+        return jsonify({'error': 'Failed to update list group'}), 500
 
-@app.route('/api/lists/<int:list_id>', methods=['DELETE'])
+@app.route('/api/list-groups/<int:group_id>', methods=['DELETE'])
 @jwt_required()
-def delete_shopping_list(list_id):
+def delete_list_group(group_id):
     try:
         user_id = int(get_jwt_identity())
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Check if user owns the list
-                cur.execute(
-                    "SELECT id, name FROM shopping_lists WHERE id = %s AND owner_id = %s",
-                    (list_id, user_id)
-                )
-                list_data = cur.fetchone()
-                
-                if not list_data:
-                    return jsonify({'error': 'Shopping list not found'}), 404
+                # Lists in the group are kept; the FK sets their group_id to NULL
+                cur.execute("""
+                    DELETE FROM list_groups
+                    WHERE id = %s AND owner_id = %s
+                    RETURNING id, name
+                """, (group_id, user_id))
                 
-                # Delete the list (CASCADE will delete items automatically)
-                cur.execute(
-                    "DELETE FROM shopping_lists WHERE id = %s AND owner_id = %s",
-                    (list_id, user_id)
-                )
+                group = cur.fetchone()
+                if not group:
+                    return jsonify({'error': 'List group not found'}), 404
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': f'Shopping list "{list_data["name"]}" deleted successfully'
+                    'message': f'List group "{group["name"]}" deleted successfully'
                 }), 200
                 
     except Exception as e:
-        print(f"Delete shopping list error: {e}")
-        return jsonify({'error': 'Failed to delete shopping list'}), 500
+        print(f"Delete list group error: {e}")
+        return jsonify({'error': 'Failed to delete list group'}), 500
 
 @app.route('/api/users/default-list', methods=['PUT'])
 @jwt_required()
@@ -827,7 +4622,7 @@ def get_default_list():
                 if default_list_id:
                     # Get the default list details
                     cur.execute("""
-                        SELECT id, name, is_shared, created_at, updated_at
+                        SELECT id, name, color, icon, is_shared, created_at, updated_at
                         FROM shopping_lists
                         WHERE id = %s AND owner_id = %s
                     """, (default_list_id, user_id))
@@ -848,52 +4643,216 @@ def get_default_list():
         print(f"Get default list error: {e}")
         return jsonify({'error': 'Failed to get default shopping list'}), 500
 
+# Calendar feed routes
+def calendar_feed_url(list_id, token):
+    """Public URL of a list's calendar feed (API_PUBLIC_URL when the API sits behind a proxy)"""
+    base_url = os.getenv('API_PUBLIC_URL') or request.url_root
+    return f"{base_url.rstrip('/')}/api/lists/{list_id}/calendar.ics?token={token}"
+
+@app.route('/api/lists/<int:list_id>/calendar-feed', methods=['POST'])
+@jwt_required()
+def create_calendar_feed(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not is_list_member(cur, list_id, user_id):
+                    return jsonify({'error': 'Shopping list not found or access denied'}), 404
+                
+                # Calendar apps can't send an Authorization header, so the feed URL carries its own token.
+                # Only its hash is stored; creating a new feed replaces the old URL.
+                token = secrets.token_urlsafe(32)
+                cur.execute("""
+                    INSERT INTO calendar_feeds (user_id, list_id, token_hash)
+                    VALUES (%s, %s, %s)
+                    ON CONFLICT (user_id, list_id)
+                    DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP, last_used_at = NULL
+                """, (user_id, list_id, hash_token(token)))
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Calendar feed created',
+                    'url': calendar_feed_url(list_id, token)
+                }), 201
+                
+    except Exception as e:
+        print(f"Create calendar feed error: {e}")
+        return jsonify({'error': 'Failed to create calendar feed'}), 500
+
+@app.route('/api/lists/<int:list_id>/calendar-feed', methods=['DELETE'])
+@jwt_required()
+def delete_calendar_feed(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "DELETE FROM calendar_feeds WHERE user_id = %s AND list_id = %s",
+                    (user_id, list_id)
+                )
+                
+                if cur.rowcount == 0:
+                    return jsonify({'error': 'Calendar feed not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({'message': 'Calendar feed revoked'}), 200
+                
+    except Exception as e:
+        print(f"Delete calendar feed error: {e}")
+        return jsonify({'error': 'Failed to revoke calendar feed'}), 500
+
+@app.route('/api/lists/<int:list_id>/calendar.ics', methods=['GET'])
+def get_list_calendar(list_id):
+    try:
+        token = request.args.get('token', '')
+        if not token:
+            return jsonify({'error': 'token is required'}), 401
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    UPDATE calendar_feeds SET last_used_at = CURRENT_TIMESTAMP
+                    WHERE list_id = %s AND token_hash = %s
+                    RETURNING user_id
+                """, (list_id, hash_token(token)))
+                feed = cur.fetchone()
+                
+                # The feed stops working once its owner loses access to the list
+                if not feed or not is_list_member(cur, list_id, feed['user_id']):
+                    return jsonify({'error': 'Calendar feed not found'}), 404
+                
+                cur.execute("SELECT name FROM shopping_lists WHERE id = %s", (list_id,))
+                list_name = cur.fetchone()['name']
+                
+                cur.execute("""
+                    SELECT id, name, quantity, amount, notes, due_date
+                    FROM shopping_list_items
+                    WHERE list_id = %s AND due_date IS NOT NULL AND completed = FALSE AND deleted_at IS NULL
+                    ORDER BY due_date, id
+                """, (list_id,))
+                items = cur.fetchall()
+                
+                conn.commit()
+                
+                events = [{
+                    'uid': f"shopping-list-item-{item['id']}",
+                    'summary': f"{item['name']} ({list_name})",
+                    'description': '\n'.join(filter(None, [
+                        f"Quantity: {item['amount'] or item['quantity']}",
+                        item['notes']
+                    ])),
+                    'date': item['due_date']
+                } for item in items]
+                
+                calendar = build_calendar(list_name, events, request.host.split(':')[0])
+                return app.response_class(calendar, mimetype='text/calendar', headers={
+                    'Content-Disposition': f'inline; filename="list-{list_id}.ics"'
+                })
+                
+    except Exception as e:
+        print(f"Get list calendar error: {e}")
+        return jsonify({'error': 'Failed to build calendar'}), 500
+
 # Shopping list sharing routes
 @app.route('/api/lists/<int:list_id>/share', methods=['POST'])
 @jwt_required()
 def generate_share_link(list_id):
     try:
         user_id = int(get_jwt_identity())
+        schema = ShareLinkSchema()
+        data = schema.load(request.get_json(silent=True) or {})
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify user owns the list
-                cur.execute(
-                    "SELECT id, name FROM shopping_lists WHERE id = %s AND owner_id = %s",
-                    (list_id, user_id)
-                )
+                if not can_manage_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute("SELECT id, name FROM shopping_lists WHERE id = %s", (list_id,))
                 list_data = cur.fetchone()
                 
-                if not list_data:
-                    return jsonify({'error': 'Shopping list not found or not owned by user'}), 404
+                if sharing_blocked_by_verification(cur, user_id):
+                    return jsonify({'error': 'Please verify your email address before sharing lists'}), 403
                 
                 # Generate a secure random token
                 share_token = secrets.token_urlsafe(32)
                 
-                # Update the list with the share token
-                cur.execute(
-                    "UPDATE shopping_lists SET share_token = %s WHERE id = %s",
-                    (share_token, list_id)
-                )
+                # Update the list with the share token; a new link starts with no uses
+                cur.execute("""
+                    UPDATE shopping_lists
+                    SET share_token = %s, share_link_max_uses = %s, share_link_uses = 0
+                    WHERE id = %s
+                """, (share_token, data.get('max_uses'), list_id))
                 
                 conn.commit()
                 
-                # Create frontend URL (default to localhost:3000 for development)
-                frontend_url = os.getenv('FRONTEND_URL', 'http://localhost:3000/')
-                if not frontend_url.endswith('/'):
-                    frontend_url += '/'
-                
                 return jsonify({
                     'message': 'Share link generated successfully',
                     'share_token': share_token,
-                    'share_url': f"{frontend_url}s/{share_token}",
-                    'list_name': list_data['name']
+                    'share_url': frontend_link(f"s/{share_token}"),
+                    'list_name': list_data['name'],
+                    'max_uses': data.get('max_uses')
                 }), 200
                 
+    except ValidationError as e:
+        return validation_error_response(e)
     except Exception as e:
         print(f"Generate share link error: {e}")
         return jsonify({'error': 'Failed to generate share link'}), 500
 
+@app.route('/api/lists/<int:list_id>/sharing', methods=['PUT'])
+@jwt_required()
+def update_list_sharing(list_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = ListSharingSchema()
+        data = schema.load(request.json)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                if not can_manage_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
+                
+                cur.execute(
+                    "SELECT id, share_token FROM shopping_lists WHERE id = %s FOR UPDATE",
+                    (list_id,)
+                )
+                list_data = cur.fetchone()
+                
+                if data['is_shared']:
+                    if sharing_blocked_by_verification(cur, user_id):
+                        return jsonify({'error': 'Please verify your email address before sharing lists'}), 403
+                    # Keep an existing link working rather than rotating it
+                    share_token = list_data['share_token'] or secrets.token_urlsafe(32)
+                else:
+                    # Disables joining through the link; explicit collaborators keep their access
+                    share_token = None
+                
+                # A replaced link starts counting its uses afresh
+                cur.execute("""
+                    UPDATE shopping_lists
+                    SET is_shared = %s, share_token = %s,
+                        share_link_uses = CASE WHEN share_token IS DISTINCT FROM %s THEN 0 ELSE share_link_uses END
+                    WHERE id = %s
+                """, (data['is_shared'], share_token, share_token, list_id))
+                
+                conn.commit()
+                
+                return jsonify({
+                    'is_shared': data['is_shared'],
+                    'share_token': share_token,
+                    'share_url': frontend_link(f"s/{share_token}") if share_token else None
+                })
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Update list sharing error: {e}")
+        return jsonify({'error': 'Failed to update list sharing'}), 500
+
 @app.route('/api/shared/<string:share_token>', methods=['GET'])
 def get_shared_shopping_list(share_token):
     try:
@@ -901,7 +4860,7 @@ def get_shared_shopping_list(share_token):
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 # Get list info by share token
                 cur.execute("""
-                    SELECT sl.id, sl.name, sl.created_at, sl.updated_at,
+                    SELECT sl.id, sl.name, sl.color, sl.icon, sl.created_at, sl.updated_at,
                            u.username as owner_username
                     FROM shopping_lists sl
                     JOIN users u ON sl.owner_id = u.id
@@ -913,10 +4872,10 @@ def get_shared_shopping_list(share_token):
                     return jsonify({'error': 'Shared shopping list not found'}), 404
                 
                 # Get list items
-                cur.execute("""
-                    SELECT id, name, quantity, category, priority, notes, completed, created_at, updated_at
+                cur.execute(f"""
+                    SELECT {ITEM_COLUMNS}
                     FROM shopping_list_items
-                    WHERE list_id = %s
+                    WHERE list_id = %s AND deleted_at IS NULL
                     ORDER BY completed ASC, created_at DESC
                 """, (list_data['id'],))
                 
@@ -930,8 +4889,69 @@ def get_shared_shopping_list(share_token):
                 })
                 
     except Exception as e:
-        print(f"Get shared shopping list error: {e}")
-        return jsonify({'error': 'Failed to get shared shopping list'}), 500
+        print(f"Get shared shopping list error: {e}")
+        return jsonify({'error': 'Failed to get shared shopping list'}), 500
+
+@app.route('/api/shared/<string:share_token>/join', methods=['POST'])
+@jwt_required()
+def join_shared_list(share_token):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "SELECT id, name, owner_id FROM shopping_lists WHERE share_token = %s",
+                    (share_token,)
+                )
+                list_data = cur.fetchone()
+                if not list_data:
+                    return jsonify({'error': 'Invalid share token'}), 404
+                
+                list_id = list_data['id']
+                # Members already have access, so joining again doesn't use up the link
+                if is_list_member(cur, list_id, user_id):
+                    return jsonify({'message': 'You already have access to this list', 'list_id': list_id}), 200
+                
+                # Count the use atomically so concurrent joins can't exceed max_uses
+                cur.execute("""
+                    UPDATE shopping_lists
+                    SET share_link_uses = share_link_uses + 1
+                    WHERE id = %s AND (share_link_max_uses IS NULL OR share_link_uses < share_link_max_uses)
+                    RETURNING share_link_uses
+                """, (list_id,))
+                if not cur.fetchone():
+                    return jsonify({'error': 'This share link has reached its maximum number of uses'}), 410
+                
+                # Joining also accepts a pending invitation, keeping its permission
+                cur.execute("""
+                    INSERT INTO list_shares (list_id, user_id, permission, status)
+                    VALUES (%s, %s, 'read', 'accepted')
+                    ON CONFLICT (list_id, user_id)
+                    DO UPDATE SET status = 'accepted', shared_at = CURRENT_TIMESTAMP
+                    RETURNING permission
+                """, (list_id, user_id))
+                permission = cur.fetchone()['permission']
+                
+                cur.execute("SELECT username FROM users WHERE id = %s", (user_id,))
+                username = cur.fetchone()['username']
+                create_notification(
+                    cur, list_data['owner_id'], 'share_joined', 'Someone Joined Your List',
+                    f'{username} joined "{list_data["name"]}" through its share link',
+                    {'list_id': list_id, 'user_id': user_id}
+                )
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Joined shopping list',
+                    'list_id': list_id,
+                    'permission': permission
+                }), 201
+                
+    except Exception as e:
+        print(f"Join shared list error: {e}")
+        return jsonify({'error': 'Failed to join shared list'}), 500
 
 @app.route('/api/shared/<string:share_token>/items/<int:item_id>/toggle', methods=['PUT'])
 def toggle_shared_item(share_token, item_id):
@@ -949,17 +4969,23 @@ def toggle_shared_item(share_token, item_id):
                     return jsonify({'error': 'Invalid share token'}), 404
                 
                 # Toggle the item's completed status
-                cur.execute("""
+                cur.execute(f"""
                     UPDATE shopping_list_items 
-                    SET completed = NOT completed, updated_at = CURRENT_TIMESTAMP
-                    WHERE id = %s AND list_id = %s
-                    RETURNING id, completed
+                    SET completed = NOT completed, completed_by = NULL, updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s AND list_id = %s AND deleted_at IS NULL
+                    RETURNING {ITEM_COLUMNS}
                 """, (item_id, list_data['id']))
                 
                 item = cur.fetchone()
                 if not item:
                     return jsonify({'error': 'Item not found'}), 404
                 
+                # Anonymous share-link changes are recorded without a user
+                record_item_history(cur, list_data['id'], item_id, None, 'updated',
+                                    {'completed': not item['completed']}, {'completed': item['completed']})
+                if item['completed']:
+                    schedule_recurrence(cur, list_data['id'], item, None)
+                
                 conn.commit()
                 
                 return jsonify({
@@ -1011,32 +5037,32 @@ def search_users():
 def invite_user_to_list(list_id):
     try:
         user_id = int(get_jwt_identity())
-        data = request.json
-        
-        if not data or 'username' not in data:
-            return jsonify({'error': 'Username is required'}), 400
+        schema = ListInviteSchema()
+        data = schema.load(request.json)
         
         username = data['username'].strip()
-        permission = data.get('permission', 'read')  # 'read' or 'write'
-        
-        if permission not in ['read', 'write']:
-            return jsonify({'error': 'Invalid permission level'}), 400
+        permission = data['permission']  # 'read' or 'write'
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Verify list ownership
+                permission_level = get_list_permission(cur, list_id, user_id)
+                if permission_level is None or permission_level < ListPermission.ADMIN:
+                    return list_access_denied(cur, list_id, user_id)
+                if not can_grant_permission(permission_level, permission):
+                    return jsonify({'error': 'Only the owner can grant admin access'}), 403
+                
                 cur.execute(
-                    "SELECT id, name FROM shopping_lists WHERE id = %s AND owner_id = %s",
-                    (list_id, user_id)
+                    "SELECT id, name, owner_id FROM shopping_lists WHERE id = %s",
+                    (list_id,)
                 )
                 list_data = cur.fetchone()
                 
-                if not list_data:
-                    return jsonify({'error': 'Shopping list not found or not owned by user'}), 404
+                if sharing_blocked_by_verification(cur, user_id):
+                    return jsonify({'error': 'Please verify your email address before sharing lists'}), 403
                 
                 # Find the user to invite
                 cur.execute(
-                    "SELECT id, username, email FROM users WHERE username = %s",
+                    "SELECT id, username, email FROM users WHERE LOWER(username) = LOWER(%s)",
                     (username,)
                 )
                 invite_user = cur.fetchone()
@@ -1046,6 +5072,17 @@ def invite_user_to_list(list_id):
                 
                 if invite_user['id'] == user_id:
                     return jsonify({'error': 'Cannot invite yourself'}), 400
+                if invite_user['id'] == list_data['owner_id']:
+                    return jsonify({'error': 'The list owner already has access'}), 400
+                
+                # Invitations notify another user, so cap how many one user can send
+                cur.execute("""
+                    SELECT COUNT(*) AS sent FROM notifications
+                    WHERE type = 'share_invitation' AND (data->>'inviter_user_id')::int = %s
+                      AND created_at > CURRENT_TIMESTAMP - INTERVAL '1 hour'
+                """, (user_id,))
+                if cur.fetchone()['sent'] >= INVITATIONS_PER_HOUR:
+                    return jsonify({'error': 'Too many invitations sent, please try again later'}), 429
                 
                 # Check if already shared
                 cur.execute(
@@ -1093,197 +5130,687 @@ def invite_user_to_list(list_id):
                     'share_id': share_id
                 }
                 
-                cur.execute("""
-                    INSERT INTO notifications (user_id, type, title, message, data)
-                    VALUES (%s, %s, %s, %s, %s)
-                """, (
-                    invite_user['id'],
-                    'share_invitation',
-                    f'Shopping List Invitation',
+                create_notification(
+                    cur, invite_user['id'], 'share_invitation', 'Shopping List Invitation',
                     f'{inviter["username"]} invited you to collaborate on "{list_data["name"]}" with {permission} access',
-                    psycopg2.extras.Json(notification_data)
-                ))
+                    notification_data
+                )
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'Invitation sent to {username}',
+                    'invited_user': {
+                        'id': invite_user['id'],
+                        'username': invite_user['username']
+                    }
+                }), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Invite user error: {e}")
+        return jsonify({'error': 'Failed to send invitation'}), 500
+
+@app.route('/api/notifications', methods=['GET'])
+@jwt_required()
+def get_notifications():
+    try:
+        user_id = int(get_jwt_identity())
+        search = request.args.get('q', '').strip()
+        notification_type = request.args.get('type')
+        unread_only = request.args.get('unread_only', '').lower() == 'true'
+        include_snoozed = request.args.get('include_snoozed', '').lower() == 'true'
+        
+        if notification_type is not None and notification_type not in NOTIFICATION_TYPES:
+            return jsonify({'error': f"type must be one of: {', '.join(NOTIFICATION_TYPES)}"}), 400
+        
+        since = request.args.get('since')
+        if since is not None:
+            try:
+                since = datetime.fromisoformat(since)
+            except ValueError:
+                return jsonify({'error': 'since must be an ISO 8601 timestamp'}), 400
+        
+        try:
+            limit, offset = parse_pagination(default_limit=50)
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        
+        # Every filter except unread_only also applies to unread_count
+        filters = ['user_id = %s']
+        params = [user_id]
+        if not include_snoozed:
+            filters.append('(snoozed_until IS NULL OR snoozed_until <= CURRENT_TIMESTAMP)')
+        if search:
+            filters.append('(title ILIKE %s OR message ILIKE %s)')
+            params += [f'%{search}%', f'%{search}%']
+        if notification_type:
+            filters.append('type = %s')
+            params.append(notification_type)
+        if since:
+            filters.append('created_at >= %s')
+            params.append(since)
+        where = ' AND '.join(filters)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"""
+                    SELECT COUNT(*) AS total, COUNT(*) FILTER (WHERE is_read = FALSE) AS unread
+                    FROM notifications
+                    WHERE {where}
+                """, params)
+                counts = cur.fetchone()
+                
+                if unread_only:
+                    where += ' AND is_read = FALSE'
+                
+                cur.execute(f"""
+                    SELECT id, type, title, message, data, is_read, snoozed_until, created_at
+                    FROM notifications
+                    WHERE {where}
+                    ORDER BY created_at DESC, id DESC
+                    LIMIT %s OFFSET %s
+                """, params + [limit, offset])
+                
+                notifications = cur.fetchall()
+                
+                return jsonify({
+                    'notifications': [dict(notification) for notification in notifications],
+                    'total': counts['unread'] if unread_only else counts['total'],
+                    'unread_count': counts['unread'],
+                    'limit': limit,
+                    'offset': offset
+                })
+                
+    except Exception as e:
+        print(f"Get notifications error: {e}")
+        return jsonify({'error': 'Failed to get notifications'}), 500
+
+@app.route('/api/notifications/<int:notification_id>/respond', methods=['POST'])
+@jwt_required()
+def respond_to_notification(notification_id):
+    try:
+        user_id = int(get_jwt_identity())
+        data = request.json
+        
+        if not data or 'action' not in data:
+            return jsonify({'error': 'Action is required'}), 400
+        
+        action = data['action']  # 'accept' or 'decline'
+        
+        if action not in ['accept', 'decline']:
+            return jsonify({'error': 'Invalid action'}), 400
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Get notification
+                cur.execute("""
+                    SELECT id, type, data
+                    FROM notifications
+                    WHERE id = %s AND user_id = %s AND type = 'share_invitation'
+                """, (notification_id, user_id))
+                
+                notification = cur.fetchone()
+                
+                if not notification:
+                    return jsonify({'error': 'Notification not found'}), 404
+                
+                notification_data = notification['data']
+                share_id = notification_data['share_id']
+                list_id = notification_data['list_id']
+                inviter_user_id = notification_data['inviter_user_id']
+                
+                if action == 'accept':
+                    # Update share status to accepted
+                    cur.execute(
+                        "UPDATE list_shares SET status = 'accepted' WHERE id = %s",
+                        (share_id,)
+                    )
+                    
+                    # Create success notification for inviter
+                    create_notification(
+                        cur, inviter_user_id, 'share_accepted', 'Invitation Accepted',
+                        f'Your invitation to share "{notification_data["list_name"]}" was accepted',
+                        {'list_id': list_id}
+                    )
+                    
+                else:  # decline
+                    # Remove the share
+                    cur.execute(
+                        "DELETE FROM list_shares WHERE id = %s",
+                        (share_id,)
+                    )
+                    
+                    # Create declined notification for inviter
+                    create_notification(
+                        cur, inviter_user_id, 'share_declined', 'Invitation Declined',
+                        f'Your invitation to share "{notification_data["list_name"]}" was declined',
+                        {'list_id': list_id}
+                    )
+                
+                # Mark notification as read
+                cur.execute(
+                    "UPDATE notifications SET is_read = TRUE WHERE id = %s",
+                    (notification_id,)
+                )
                 
                 conn.commit()
                 
                 return jsonify({
-                    'message': f'Invitation sent to {username}',
-                    'invited_user': {
-                        'id': invite_user['id'],
-                        'username': invite_user['username']
-                    }
-                }), 200
+                    'message': f'Invitation {action}ed successfully'
+                }), 200
+                
+    except Exception as e:
+        print(f"Respond to notification error: {e}")
+        return jsonify({'error': 'Failed to respond to notification'}), 500
+
+@app.route('/api/notifications/<int:notification_id>/read', methods=['PUT'])
+@jwt_required()
+def mark_notification_read(notification_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "UPDATE notifications SET is_read = TRUE WHERE id = %s AND user_id = %s",
+                    (notification_id, user_id)
+                )
+                
+                conn.commit()
+                
+                return jsonify({'message': 'Notification marked as read'}), 200
+                
+    except Exception as e:
+        print(f"Mark notification read error: {e}")
+        return jsonify({'error': 'Failed to mark notification as read'}), 500
+
+@app.route('/api/notifications/<int:notification_id>/snooze', methods=['POST'])
+@jwt_required()
+def snooze_notification(notification_id):
+    try:
+        user_id = int(get_jwt_identity())
+        data = NotificationSnoozeSchema().load(request.json or {})
+        
+        now = datetime.utcnow()
+        if 'minutes' in data:
+            until = now + timedelta(minutes=data['minutes'])
+        else:
+            until = data['until'].astimezone(timezone.utc).replace(tzinfo=None)
+            if until <= now:
+                return jsonify({'error': 'until must be in the future'}), 400
+            if until > now + timedelta(minutes=MAX_SNOOZE_MINUTES):
+                return jsonify({'error': f'Notifications can be snoozed for at most {MAX_SNOOZE_MINUTES // (24 * 60)} days'}), 400
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Snoozed notifications come back unread
+                cur.execute("""
+                    UPDATE notifications
+                    SET snoozed_until = %s, is_read = FALSE
+                    WHERE id = %s AND user_id = %s
+                    RETURNING id, type, title, message, data, is_read, snoozed_until, created_at
+                """, (until, notification_id, user_id))
+                
+                notification = cur.fetchone()
+                if not notification:
+                    return jsonify({'error': 'Notification not found'}), 404
+                
+                return jsonify({'notification': dict(notification)}), 200
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Snooze notification error: {e}")
+        return jsonify({'error': 'Failed to snooze notification'}), 500
+
+@app.route('/api/notifications/unread-count', methods=['GET'])
+@jwt_required()
+def get_unread_notification_count():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT COUNT(*) AS unread
+                    FROM notifications
+                    WHERE user_id = %s AND is_read = FALSE
+                      AND (snoozed_until IS NULL OR snoozed_until <= CURRENT_TIMESTAMP)
+                """, (user_id,))
+                
+                return jsonify({'unread_count': cur.fetchone()['unread']})
+                
+    except Exception as e:
+        print(f"Get unread notification count error: {e}")
+        return jsonify({'error': 'Failed to get unread notification count'}), 500
+
+@app.route('/api/notifications/<int:notification_id>', methods=['GET'])
+@jwt_required()
+def get_notification(notification_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT id, type, title, message, data, is_read, snoozed_until, created_at
+                    FROM notifications
+                    WHERE id = %s AND user_id = %s
+                """, (notification_id, user_id))
+                
+                notification = cur.fetchone()
+                if not notification:
+                    return jsonify({'error': 'Notification not found'}), 404
+                
+                return jsonify({'notification': dict(notification)})
+                
+    except Exception as e:
+        print(f"Get notification error: {e}")
+        return jsonify({'error': 'Failed to get notification'}), 500
+
+@app.route('/api/notifications/<int:notification_id>', methods=['DELETE'])
+@jwt_required()
+def delete_notification(notification_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(
+                    "DELETE FROM notifications WHERE id = %s AND user_id = %s",
+                    (notification_id, user_id)
+                )
+                
+                if cur.rowcount == 0:
+                    return jsonify({'error': 'Notification not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({'message': 'Notification deleted successfully'}), 200
+                
+    except Exception as e:
+        print(f"Delete notification error: {e}")
+        return jsonify({'error': 'Failed to delete notification'}), 500
+
+# Sync routes
+# Deletions older than this are purged, so clients that fell further behind must sync from scratch
+SYNC_TOMBSTONE_RETENTION = timedelta(days=int(os.getenv('SYNC_TOMBSTONE_RETENTION_DAYS', 30)))
+# Rows are stamped with their transaction's start time, so one that commits after server_time
+# was taken can carry an older timestamp; each sync re-reads this far back to pick those up
+SYNC_OVERLAP = timedelta(seconds=int(os.getenv('SYNC_OVERLAP_SECONDS', 60)))
+
+@app.route('/api/sync', methods=['GET'])
+@jwt_required()
+def sync_changes():
+    try:
+        user_id = int(get_jwt_identity())
+        
+        since = request.args.get('since')
+        if since is not None:
+            try:
+                since = datetime.fromisoformat(since)
+            except ValueError:
+                return jsonify({'error': 'since must be an ISO 8601 timestamp'}), 400
+            if since.tzinfo is not None:
+                since = since.astimezone(timezone.utc).replace(tzinfo=None)
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # Taken first so anything written while this runs is picked up by the next sync
+                cur.execute("SELECT LOCALTIMESTAMP AS server_time")
+                server_time = cur.fetchone()['server_time']
+                
+                if since is not None and since < server_time - SYNC_TOMBSTONE_RETENTION:
+                    return jsonify({'error': 'since is older than the sync history; sync again without since'}), 410
+                
+                cur.execute("""
+                    SELECT id AS list_id, 'owner' AS role, NULL::timestamp AS joined_at
+                    FROM shopping_lists WHERE owner_id = %s
+                    UNION ALL
+                    SELECT list_id, permission, updated_at
+                    FROM list_shares WHERE user_id = %s AND status = 'accepted'
+                """, (user_id, user_id))
+                memberships = cur.fetchall()
+                
+                # Without since everything is sent; with it, changes in the overlap are sent again
+                # and clients apply them by id
+                cutoff = since - SYNC_OVERLAP if since is not None else datetime.min
+                list_ids = [m['list_id'] for m in memberships]
+                manage_ids = [m['list_id'] for m in memberships if m['role'] in ('owner', 'admin')]
+                # Lists joined since the last sync are sent whole, whatever their own timestamps
+                new_ids = [m['list_id'] for m in memberships if m['joined_at'] is not None and m['joined_at'] > cutoff]
+                
+                cur.execute("""
+                    SELECT sl.id, sl.name, sl.color, sl.icon, sl.is_shared,
+                           CASE WHEN sl.owner_id = %s THEN sl.group_id END AS group_id,
+                           COALESCE(ls.permission, 'owner') AS role,
+                           sl.owner_id = %s OR ls.permission IN ('write', 'admin') AS can_write,
+                           u.username AS owner_username, sl.created_at, sl.updated_at
+                    FROM shopping_lists sl
+                    JOIN users u ON u.id = sl.owner_id
+                    LEFT JOIN list_shares ls ON ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    WHERE sl.id = ANY(%s) AND (sl.id = ANY(%s) OR sl.updated_at > %s)
+                    ORDER BY sl.id
+                """, (user_id, user_id, user_id, list_ids, new_ids, cutoff))
+                lists = cur.fetchall()
+                
+                cur.execute(f"""
+                    SELECT list_id, {ITEM_COLUMNS}
+                    FROM shopping_list_items
+                    WHERE list_id = ANY(%s) AND deleted_at IS NULL
+                      AND (list_id = ANY(%s) OR updated_at > %s)
+                    ORDER BY list_id, id
+                """, (list_ids, new_ids, cutoff))
+                items = cur.fetchall()
+                
+                # Invitations and memberships of the user, plus every share on lists they manage
+                cur.execute("""
+                    SELECT ls.id, ls.list_id, ls.user_id, u.username, ls.permission, ls.status,
+                           ls.shared_at, ls.updated_at
+                    FROM list_shares ls
+                    JOIN users u ON u.id = ls.user_id
+                    WHERE (ls.user_id = %s OR ls.list_id = ANY(%s)) AND ls.updated_at > %s
+                    ORDER BY ls.id
+                """, (user_id, manage_ids, cutoff))
+                shares = cur.fetchall()
+                
+                cur.execute("""
+                    SELECT id, type, title, message, data, is_read, snoozed_until, created_at, updated_at
+                    FROM notifications
+                    WHERE user_id = %s AND updated_at > %s
+                    ORDER BY id
+                """, (user_id, cutoff))
+                notifications = cur.fetchall()
+                
+                deleted = {'lists': set(), 'items': set(), 'shares': set(), 'notifications': set()}
+                if since is not None:
+                    cur.execute("""
+                        SELECT entity, entity_id, list_id, user_id
+                        FROM sync_tombstones
+                        WHERE deleted_at > %s AND (user_id = %s OR list_id = ANY(%s))
+                    """, (cutoff, user_id, list_ids))
+                    for tombstone in cur.fetchall():
+                        entity, entity_id = tombstone['entity'], tombstone['entity_id']
+                        if entity == 'item':
+                            deleted['items'].add(entity_id)
+                        elif entity == 'share':
+                            if tombstone['user_id'] == user_id or tombstone['list_id'] in manage_ids:
+                                deleted['shares'].add(entity_id)
+                            # Losing a share means losing the list, unless access was regained since
+                            if tombstone['user_id'] == user_id and tombstone['list_id'] not in list_ids:
+                                deleted['lists'].add(tombstone['list_id'])
+                        elif tombstone['user_id'] == user_id:
+                            deleted[f'{entity}s'].add(entity_id)
+                    
+                    # Trashed items are deleted as far as the client is concerned
+                    cur.execute("""
+                        SELECT id FROM shopping_list_items
+                        WHERE list_id = ANY(%s) AND deleted_at > %s
+                    """, (list_ids, cutoff))
+                    deleted['items'].update(row['id'] for row in cur.fetchall())
+                
+                return jsonify({
+                    'server_time': server_time.isoformat() + 'Z',
+                    'lists': lists,
+                    'items': items,
+                    'shares': shares,
+                    'notifications': [dict(notification) for notification in notifications],
+                    'deleted': {entity: sorted(ids) for entity, ids in deleted.items()}
+                })
                 
     except Exception as e:
-        print(f"Invite user error: {e}")
-        return jsonify({'error': 'Failed to send invitation'}), 500
+        print(f"Sync error: {e}")
+        return jsonify({'error': 'Failed to sync'}), 500
 
-@app.route('/api/notifications', methods=['GET'])
+# Webhook routes
+WEBHOOK_COLUMNS = "id, url, events, enabled, created_at, updated_at"
+
+webhook_dispatcher = create_webhook_dispatcher()
+
+def check_webhook_target(url):
+    """Reject webhook URLs whose host is unresolvable or internal, as a url field error"""
+    try:
+        webhook_dispatcher.check_url(url)
+    except UnsafeWebhookURL as e:
+        raise ValidationError({'url': [str(e)]})
+
+@app.route('/api/webhooks', methods=['GET'])
 @jwt_required()
-def get_notifications():
+def get_webhooks():
     try:
         user_id = int(get_jwt_identity())
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                cur.execute("""
-                    SELECT id, type, title, message, data, is_read, created_at
-                    FROM notifications
+                cur.execute(f"""
+                    SELECT {WEBHOOK_COLUMNS}
+                    FROM webhooks
                     WHERE user_id = %s
-                    ORDER BY created_at DESC
-                    LIMIT 50
+                    ORDER BY created_at
                 """, (user_id,))
                 
-                notifications = cur.fetchall()
+                webhooks = cur.fetchall()
                 
-                return jsonify({
-                    'notifications': [dict(notification) for notification in notifications]
-                })
+                return jsonify({'webhooks': [dict(webhook) for webhook in webhooks]})
                 
     except Exception as e:
-        print(f"Get notifications error: {e}")
-        return jsonify({'error': 'Failed to get notifications'}), 500
+        print(f"Get webhooks error: {e}")
+        return jsonify({'error': 'Failed to get webhooks'}), 500
 
-@app.route('/api/notifications/<int:notification_id>/respond', methods=['POST'])
+@app.route('/api/webhooks', methods=['POST'])
 @jwt_required()
-def respond_to_notification(notification_id):
+def create_webhook():
     try:
         user_id = int(get_jwt_identity())
-        data = request.json
-        
-        if not data or 'action' not in data:
-            return jsonify({'error': 'Action is required'}), 400
+        schema = WebhookSchema()
+        data = schema.load(request.json)
+        check_webhook_target(data['url'])
+        secret = data.get('secret') or secrets.token_urlsafe(32)
         
-        action = data['action']  # 'accept' or 'decline'
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("SELECT COUNT(*) AS count FROM webhooks WHERE user_id = %s", (user_id,))
+                if cur.fetchone()['count'] >= MAX_WEBHOOKS_PER_USER:
+                    return jsonify({'error': f'You can have at most {MAX_WEBHOOKS_PER_USER} webhooks'}), 400
+                
+                cur.execute(f"""
+                    INSERT INTO webhooks (user_id, url, secret, events, enabled)
+                    VALUES (%s, %s, %s, %s, %s)
+                    RETURNING {WEBHOOK_COLUMNS}
+                """, (user_id, data['url'], secret, sorted(set(data['events'])), data['enabled']))
+                
+                webhook = dict(cur.fetchone())
+                
+                conn.commit()
+                
+                # The secret is shown once, so the receiver can verify X-Webhook-Signature
+                return jsonify({
+                    'message': 'Webhook created successfully',
+                    'webhook': {**webhook, 'secret': secret}
+                }), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Create webhook error: {e}")
+        return jsonify({'error': 'Failed to create webhook'}), 500
+
+@app.route('/api/webhooks/<int:webhook_id>', methods=['PUT'])
+@jwt_required()
+def update_webhook(webhook_id):
+    try:
+        user_id = int(get_jwt_identity())
+        schema = WebhookSchema(partial=True)
+        data = schema.load(request.json)
+        if 'url' in data:
+            check_webhook_target(data['url'])
+        if 'events' in data:
+            data['events'] = sorted(set(data['events']))
         
-        if action not in ['accept', 'decline']:
-            return jsonify({'error': 'Invalid action'}), 400
+        fields_to_update = [field for field in ('url', 'events', 'enabled', 'secret') if field in data]
+        if not fields_to_update:
+            return jsonify({'error': 'No fields to update'}), 400
+        set_clause = ', '.join(f'{field} = %s' for field in fields_to_update)
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Get notification
-                cur.execute("""
-                    SELECT id, type, data
-                    FROM notifications
-                    WHERE id = %s AND user_id = %s AND type = 'share_invitation'
-                """, (notification_id, user_id))
+                cur.execute(f"""
+                    UPDATE webhooks
+                    SET {set_clause}, updated_at = CURRENT_TIMESTAMP
+                    WHERE id = %s AND user_id = %s
+                    RETURNING {WEBHOOK_COLUMNS}
+                """, (*[data[field] for field in fields_to_update], webhook_id, user_id))
                 
-                notification = cur.fetchone()
-                
-                if not notification:
-                    return jsonify({'error': 'Notification not found'}), 404
+                webhook = cur.fetchone()
+                if not webhook:
+                    return jsonify({'error': 'Webhook not found'}), 404
                 
-                notification_data = notification['data']
-                share_id = notification_data['share_id']
-                list_id = notification_data['list_id']
-                inviter_user_id = notification_data['inviter_user_id']
+                conn.commit()
                 
-                if action == 'accept':
-                    # Update share status to accepted
-                    cur.execute(
-                        "UPDATE list_shares SET status = 'accepted' WHERE id = %s",
-                        (share_id,)
-                    )
-                    
-                    # Create success notification for inviter
-                    cur.execute("""
-                        INSERT INTO notifications (user_id, type, title, message, data)
-                        VALUES (%s, %s, %s, %s, %s)
-                    """, (
-                        inviter_user_id,
-                        'share_accepted',
-                        'Invitation Accepted',
-                        f'Your invitation to share "{notification_data["list_name"]}" was accepted',
-                        psycopg2.extras.Json({'list_id': list_id})
-                    ))
-                    
-                else:  # decline
-                    # Remove the share
-                    cur.execute(
-                        "DELETE FROM list_shares WHERE id = %s",
-                        (share_id,)
-                    )
-                    
-                    # Create declined notification for inviter
-                    cur.execute("""
-                        INSERT INTO notifications (user_id, type, title, message, data)
-                        VALUES (%s, %s, %s, %s, %s)
-                    """, (
-                        inviter_user_id,
-                        'share_declined',
-                        'Invitation Declined',
-                        f'Your invitation to share "{notification_data["list_name"]}" was declined',
-                        psycopg2.extras.Json({'list_id': list_id})
-                    ))
+                return jsonify({
+                    'message': 'Webhook updated successfully',
+                    'webhook': dict(webhook)
+                })
                 
-                # Mark notification as read
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Update webhook error: {e}")
+        return jsonify({'error': 'Failed to update webhook'}), 500
+
+@app.route('/api/webhooks/<int:webhook_id>', methods=['DELETE'])
+@jwt_required()
+def delete_webhook(webhook_id):
+    try:
+        user_id = int(get_jwt_identity())
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 cur.execute(
-                    "UPDATE notifications SET is_read = TRUE WHERE id = %s",
-                    (notification_id,)
+                    "DELETE FROM webhooks WHERE id = %s AND user_id = %s",
+                    (webhook_id, user_id)
                 )
                 
+                if cur.rowcount == 0:
+                    return jsonify({'error': 'Webhook not found'}), 404
+                
                 conn.commit()
                 
-                return jsonify({
-                    'message': f'Invitation {action}ed successfully'
-                }), 200
+                return jsonify({'message': 'Webhook deleted successfully'}), 200
                 
     except Exception as e:
-        print(f"Respond to notification error: {e}")
-        return jsonify({'error': 'Failed to respond to notification'}), 500
+        print(f"Delete webhook error: {e}")
+        return jsonify({'error': 'Failed to delete webhook'}), 500
 
-@app.route('/api/notifications/<int:notification_id>/read', methods=['PUT'])
+@app.route('/api/webhooks/<int:webhook_id>/deliveries', methods=['GET'])
 @jwt_required()
-def mark_notification_read(notification_id):
+def get_webhook_deliveries(webhook_id):
     try:
         user_id = int(get_jwt_identity())
         
+        try:
+            limit, offset = parse_pagination(default_limit=50)
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
                 cur.execute(
-                    "UPDATE notifications SET is_read = TRUE WHERE id = %s AND user_id = %s",
-                    (notification_id, user_id)
+                    "SELECT id FROM webhooks WHERE id = %s AND user_id = %s",
+                    (webhook_id, user_id)
                 )
+                if not cur.fetchone():
+                    return jsonify({'error': 'Webhook not found'}), 404
                 
-                conn.commit()
+                cur.execute("""
+                    SELECT id, event_type, payload, status, attempts, response_status, last_error,
+                           next_attempt_at, created_at, delivered_at
+                    FROM webhook_deliveries
+                    WHERE webhook_id = %s
+                    ORDER BY created_at DESC, id DESC
+                    LIMIT %s OFFSET %s
+                """, (webhook_id, limit, offset))
                 
-                return jsonify({'message': 'Notification marked as read'}), 200
+                deliveries = cur.fetchall()
+                
+                return jsonify({
+                    'deliveries': [dict(delivery) for delivery in deliveries],
+                    'limit': limit,
+                    'offset': offset
+                })
                 
     except Exception as e:
-        print(f"Mark notification read error: {e}")
-        return jsonify({'error': 'Failed to mark notification as read'}), 500
+        print(f"Get webhook deliveries error: {e}")
+        return jsonify({'error': 'Failed to get webhook deliveries'}), 500
 
 # Sharing Management Endpoints
+SHARE_STATUSES = ('pending', 'accepted', 'declined')
+
 @app.route('/api/lists/<int:list_id>/shares', methods=['GET'])
 @jwt_required()
 def get_list_shares(list_id):
     try:
         user_id = int(get_jwt_identity())
+        status = request.args.get('status')
+        if status is not None and status not in SHARE_STATUSES:
+            return jsonify({'error': f"status must be one of: {', '.join(SHARE_STATUSES)}"}), 400
+        
+        try:
+            limit, offset = parse_pagination()
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Check if user owns the list
-                cur.execute("""
-                    SELECT owner_id FROM shopping_lists 
-                    WHERE id = %s AND owner_id = %s
-                """, (list_id, user_id))
+                if not can_manage_list(cur, list_id, user_id):
+                    return list_access_denied(cur, list_id, user_id)
                 
-                if not cur.fetchone():
-                    return jsonify({'error': 'Access denied - not list owner'}), 403
+                filters = ['ls.list_id = %s']
+                params = [list_id]
+                if status:
+                    filters.append('ls.status = %s')
+                    params.append(status)
+                where = ' AND '.join(filters)
                 
-                # Get all shares for this list
+                # Counts by status ignore the status filter so the tally always covers the whole list
                 cur.execute("""
+                    SELECT COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+                           COUNT(*) FILTER (WHERE status = 'accepted') AS accepted,
+                           COUNT(*) FILTER (WHERE status = 'declined') AS declined
+                    FROM list_shares
+                    WHERE list_id = %s
+                """, (list_id,))
+                counts = cur.fetchone()
+                total = counts[status] if status else sum(counts.values())
+                
+                page_sql = ''
+                page_params = []
+                if limit is not None:
+                    page_sql = 'LIMIT %s OFFSET %s'
+                    page_params = [limit, offset]
+                
+                # Get the shares for this list
+                cur.execute(f"""
                     SELECT ls.id, ls.permission, ls.status, ls.shared_at,
                            u.username, u.email
                     FROM list_shares ls
                     JOIN users u ON u.id = ls.user_id
-                    WHERE ls.list_id = %s
-                    ORDER BY ls.shared_at DESC
-                """, (list_id,))
+                    WHERE {where}
+                    ORDER BY ls.shared_at DESC, ls.id DESC
+                    {page_sql}
+                """, params + page_params)
                 
                 shares = cur.fetchall()
                 
-                return jsonify({'shares': shares}), 200
+                return jsonify({
+                    'shares': shares,
+                    'total': total,
+                    'counts': counts,
+                    'limit': limit,
+                    'offset': offset
+                }), 200
                 
     except Exception as e:
         print(f"Get list shares error: {e}")
@@ -1300,19 +5827,25 @@ def update_share_permission(list_id, share_id):
             return jsonify({'error': 'Permission is required'}), 400
         
         permission = data['permission']
-        if permission not in ['read', 'write']:
+        if permission not in ['read', 'write', 'admin']:
             return jsonify({'error': 'Invalid permission'}), 400
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Check if user owns the list
-                cur.execute("""
-                    SELECT owner_id FROM shopping_lists 
-                    WHERE id = %s AND owner_id = %s
-                """, (list_id, user_id))
+                permission_level = get_list_permission(cur, list_id, user_id)
+                if permission_level is None or permission_level < ListPermission.ADMIN:
+                    return list_access_denied(cur, list_id, user_id)
                 
-                if not cur.fetchone():
-                    return jsonify({'error': 'Access denied - not list owner'}), 403
+                # Admins manage read/write collaborators; only the owner grants or revokes admin
+                cur.execute(
+                    "SELECT permission FROM list_shares WHERE id = %s AND list_id = %s",
+                    (share_id, list_id)
+                )
+                share = cur.fetchone()
+                if not share:
+                    return jsonify({'error': 'Share not found'}), 404
+                if not all(can_grant_permission(permission_level, p) for p in (permission, share['permission'])):
+                    return jsonify({'error': 'Only the owner can grant or revoke admin access'}), 403
                 
                 # Update the share permission
                 cur.execute("""
@@ -1340,18 +5873,13 @@ def remove_share(list_id, share_id):
         
         with get_db_connection() as conn:
             with conn.cursor(cursor_factory=RealDictCursor) as cur:
-                # Check if user owns the list
-                cur.execute("""
-                    SELECT owner_id FROM shopping_lists 
-                    WHERE id = %s AND owner_id = %s
-                """, (list_id, user_id))
-                
-                if not cur.fetchone():
-                    return jsonify({'error': 'Access denied - not list owner'}), 403
+                permission_level = get_list_permission(cur, list_id, user_id)
+                if permission_level is None or permission_level < ListPermission.ADMIN:
+                    return list_access_denied(cur, list_id, user_id)
                 
                 # Get share info before deletion for notification
                 cur.execute("""
-                    SELECT ls.user_id, u.username, sl.name as list_name
+                    SELECT ls.user_id, ls.permission, u.username, sl.name as list_name
                     FROM list_shares ls
                     JOIN users u ON u.id = ls.user_id  
                     JOIN shopping_lists sl ON sl.id = ls.list_id
@@ -1361,6 +5889,9 @@ def remove_share(list_id, share_id):
                 share_info = cur.fetchone()
                 if not share_info:
                     return jsonify({'error': 'Share not found'}), 404
+                # Admins can remove themselves, but only the owner removes other admins
+                if share_info['user_id'] != user_id and not can_grant_permission(permission_level, share_info['permission']):
+                    return jsonify({'error': 'Only the owner can remove an admin'}), 403
                 
                 # Delete the share
                 cur.execute("""
@@ -1369,17 +5900,11 @@ def remove_share(list_id, share_id):
                 """, (share_id, list_id))
                 
                 # Create notification for removed user
-                cur.execute("""
-                    INSERT INTO notifications (user_id, type, title, message, data, is_read)
-                    VALUES (%s, %s, %s, %s, %s, %s)
-                """, (
-                    share_info['user_id'],
-                    'share_removed',
-                    'Access Removed',
+                create_notification(
+                    cur, share_info['user_id'], 'share_removed', 'Access Removed',
                     f'You no longer have access to "{share_info["list_name"]}"',
-                    psycopg2.extras.Json({'list_id': list_id}),
-                    False
-                ))
+                    {'list_id': list_id}
+                )
                 
                 # Update list sharing status if no more shares
                 cur.execute("""
@@ -1495,11 +6020,13 @@ def oidc_callback():
         
         if not user_data:
             return jsonify({'error': message}), 400
+        if is_user_disabled(user_data['id']):
+            return jsonify({'error': 'This account has been disabled'}), 403
         
         # Create JWT token for the application
-        access_token = create_access_token(identity=str(user_data['id']))
+        access_token = issue_access_token(user_data['id'])
         
-        return jsonify({
+        return token_response({
             'message': 'OIDC authentication successful',
             'user': {
                 'id': user_data['id'],
@@ -1507,9 +6034,8 @@ def oidc_callback():
                 'email': user_data['email'],
                 'auth_provider': user_data.get('auth_provider', 'authentik')
             },
-            'token': access_token,
             'sync_message': message
-        }), 200
+        }, access_token, data.get('use_cookie'))
         
     except Exception as e:
         print(f"OIDC callback error: {e}")
@@ -1593,5 +6119,344 @@ def oidc_status():
         print(f"OIDC status error: {e}")
         return jsonify({'error': 'Failed to get OIDC status'}), 500
 
+# Admin routes
+@app.route('/api/admin/users', methods=['GET'])
+@jwt_required()
+@admin_required
+def admin_get_users():
+    try:
+        search = request.args.get('q', '').strip()
+        try:
+            limit, offset = parse_pagination(default_limit=50)
+        except ValueError as e:
+            return jsonify({'error': str(e)}), 400
+        
+        where = ''
+        params = []
+        if search:
+            where = 'WHERE u.username ILIKE %s OR u.email ILIKE %s'
+            params = [f'%{search}%', f'%{search}%']
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute(f"SELECT COUNT(*) AS total FROM users u {where}", params)
+                total = cur.fetchone()['total']
+                
+                cur.execute(f"""
+                    SELECT u.id, u.username, u.email, u.email_verified, u.is_admin, u.disabled_at, u.created_at,
+                           (SELECT COUNT(*) FROM shopping_lists WHERE owner_id = u.id) AS list_count
+                    FROM users u
+                    {where}
+                    ORDER BY u.created_at DESC, u.id DESC
+                    LIMIT %s OFFSET %s
+                """, params + [limit, offset])
+                
+                users = cur.fetchall()
+                
+                return jsonify({
+                    'users': [dict(user) for user in users],
+                    'total': total,
+                    'limit': limit,
+                    'offset': offset
+                })
+                
+    except Exception as e:
+        print(f"Admin get users error: {e}")
+        return jsonify({'error': 'Failed to get users'}), 500
+
+@app.route('/api/admin/users/<int:target_user_id>/disable', methods=['POST'])
+@jwt_required()
+@admin_required
+def admin_disable_user(target_user_id):
+    try:
+        if target_user_id == int(get_jwt_identity()):
+            return jsonify({'error': 'You cannot disable your own account'}), 400
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    UPDATE users SET disabled_at = COALESCE(disabled_at, CURRENT_TIMESTAMP)
+                    WHERE id = %s
+                    RETURNING id, username, disabled_at
+                """, (target_user_id,))
+                user = cur.fetchone()
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                
+                # Signs the user out everywhere; login is refused while disabled
+                cur.execute("""
+                    UPDATE user_sessions SET revoked_at = CURRENT_TIMESTAMP
+                    WHERE user_id = %s AND revoked_at IS NULL
+                """, (target_user_id,))
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'User "{user["username"]}" disabled',
+                    'user': dict(user)
+                }), 200
+                
+    except Exception as e:
+        print(f"Admin disable user error: {e}")
+        return jsonify({'error': 'Failed to disable user'}), 500
+
+@app.route('/api/admin/users/<int:target_user_id>/enable', methods=['POST'])
+@jwt_required()
+@admin_required
+def admin_enable_user(target_user_id):
+    try:
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    UPDATE users SET disabled_at = NULL
+                    WHERE id = %s
+                    RETURNING id, username, disabled_at
+                """, (target_user_id,))
+                user = cur.fetchone()
+                if not user:
+                    return jsonify({'error': 'User not found'}), 404
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': f'User "{user["username"]}" enabled',
+                    'user': dict(user)
+                }), 200
+                
+    except Exception as e:
+        print(f"Admin enable user error: {e}")
+        return jsonify({'error': 'Failed to enable user'}), 500
+
+@app.route('/api/admin/stats', methods=['GET'])
+@jwt_required()
+@admin_required
+def admin_get_stats():
+    try:
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                cur.execute("""
+                    SELECT
+                        (SELECT COUNT(*) FROM users) AS total_users,
+                        (SELECT COUNT(*) FROM users WHERE disabled_at IS NOT NULL) AS disabled_users,
+                        (SELECT COUNT(*) FROM users WHERE is_admin) AS admin_users,
+                        (SELECT COUNT(*) FROM users WHERE created_at >= CURRENT_TIMESTAMP - INTERVAL '7 days') AS users_added_7d,
+                        (SELECT COUNT(DISTINCT user_id) FROM user_sessions
+                         WHERE last_seen_at >= CURRENT_TIMESTAMP - INTERVAL '7 days') AS active_users_7d,
+                        (SELECT COUNT(*) FROM shopping_lists) AS total_lists,
+                        (SELECT COUNT(*) FROM shopping_list_items WHERE deleted_at IS NULL) AS total_items,
+                        (SELECT COUNT(*) FROM shopping_list_items WHERE deleted_at IS NULL AND completed) AS completed_items,
+                        (SELECT COUNT(*) FROM list_shares WHERE status = 'accepted') AS accepted_shares
+                """)
+                
+                return jsonify({'stats': dict(cur.fetchone())})
+                
+    except Exception as e:
+        print(f"Admin get stats error: {e}")
+        return jsonify({'error': 'Failed to get statistics'}), 500
+
+@app.route('/api/admin/announcements', methods=['POST'])
+@jwt_required()
+@admin_required
+def admin_create_announcement():
+    try:
+        user_id = int(get_jwt_identity())
+        schema = AnnouncementSchema()
+        data = schema.load(request.json)
+        
+        filters = ['disabled_at IS NULL']
+        params = ['announcement', data['title'], data['message'],
+                  psycopg2.extras.Json({'announced_by': user_id})]
+        if 'user_ids' in data:
+            filters.append('id = ANY(%s)')
+            params.append(data['user_ids'])
+        
+        with get_db_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cur:
+                # One INSERT ... SELECT, however many users it reaches
+                cur.execute(f"""
+                    INSERT INTO notifications (user_id, type, title, message, data)
+                    SELECT id, %s, %s, %s, %s
+                    FROM users
+                    WHERE {' AND '.join(filters)}
+                """, params)
+                recipient_count = cur.rowcount
+                
+                conn.commit()
+                
+                return jsonify({
+                    'message': 'Announcement sent',
+                    'recipient_count': recipient_count
+                }), 201
+                
+    except ValidationError as e:
+        return validation_error_response(e)
+    except Exception as e:
+        print(f"Admin create announcement error: {e}")
+        return jsonify({'error': 'Failed to send announcement'}), 500
+
+# Background jobs
+def purge_trashed_items(cur):
+    """Hard-delete items that have been in the trash longer than the retention window"""
+    cur.execute(
+        "DELETE FROM shopping_list_items WHERE deleted_at < CURRENT_TIMESTAMP - %s",
+        (TRASH_RETENTION,)
+    )
+    return cur.rowcount
+
+# Delivery log entries are kept this long
+WEBHOOK_DELIVERY_RETENTION = timedelta(days=int(os.getenv('WEBHOOK_DELIVERY_RETENTION_DAYS', 30)))
+
+def purge_webhook_deliveries(cur):
+    """Drop finished webhook deliveries older than the retention window"""
+    cur.execute(
+        "DELETE FROM webhook_deliveries WHERE status IN ('delivered', 'failed') AND created_at < CURRENT_TIMESTAMP - %s",
+        (WEBHOOK_DELIVERY_RETENTION,)
+    )
+    return cur.rowcount
+
+# Opt-in digest of lists with items left, sent at most once per interval
+DIGEST_INTERVAL = timedelta(days=int(os.getenv('DIGEST_INTERVAL_DAYS', 7)))
+DIGEST_EMAIL_ENABLED = os.getenv('DIGEST_EMAIL_ENABLED', 'false').lower() == 'true'
+
+def send_digests(get_connection):
+    """
+    Notify (and optionally email) opted-in users about their lists with uncompleted items.
+    Each user is committed on its own and emailed only after that commit, so a later
+    failure can't roll back a digest that was already emailed and send it again.
+    """
+    sent = 0
+    while True:
+        with get_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute("""
+                    SELECT id, username, email FROM users
+                    WHERE digest_enabled AND disabled_at IS NULL AND NOT is_guest
+                      AND (digest_last_sent_at IS NULL OR digest_last_sent_at <= CURRENT_TIMESTAMP - %s)
+                    ORDER BY id
+                    LIMIT 1
+                    FOR UPDATE SKIP LOCKED
+                """, (DIGEST_INTERVAL,))
+                user = cur.fetchone()
+                if not user:
+                    return sent
+                user_id, username, email = user
+                
+                cur.execute("""
+                    SELECT sl.id, sl.name, COUNT(*) AS remaining
+                    FROM shopping_lists sl
+                    JOIN shopping_list_items sli ON sli.list_id = sl.id AND sli.deleted_at IS NULL AND NOT sli.completed
+                    WHERE sl.owner_id = %s OR EXISTS (
+                        SELECT 1 FROM list_shares ls
+                        WHERE ls.list_id = sl.id AND ls.user_id = %s AND ls.status = 'accepted'
+                    )
+                    GROUP BY sl.id
+                    ORDER BY remaining DESC, sl.name
+                """, (user_id, user_id))
+                lists = cur.fetchall()
+                
+                # Marked as sent even with nothing to report, so the next check waits a full interval
+                cur.execute("UPDATE users SET digest_last_sent_at = CURRENT_TIMESTAMP WHERE id = %s", (user_id,))
+                if lists:
+                    summary = ', '.join(f'{name} ({remaining})' for _, name, remaining in lists)
+                    create_notification(
+                        cur, user_id, 'digest', 'Items Left on Your Lists',
+                        f'You have items left on {len(lists)} list{"s" if len(lists) != 1 else ""}: {summary}',
+                        {'lists': [{'list_id': list_id, 'name': name, 'remaining': remaining} for list_id, name, remaining in lists]}
+                    )
+            conn.commit()
+        sent += 1
+        
+        if lists and DIGEST_EMAIL_ENABLED:
+            try:
+                mailer.send(
+                    email,
+                    'Items left on your shopping lists',
+                    f"Hi {username},\n\n"
+                    f"You still have items to get:\n"
+                    + ''.join(f"- {name}: {remaining} item{'s' if remaining != 1 else ''}\n" for _, name, remaining in lists)
+                    + f"\n{frontend_link('')}"
+                )
+            except Exception as e:
+                print(f"Send digest email error: {e}")
+
+def purge_sync_tombstones(cur):
+    """Drop deletion records older than any since GET /api/sync still accepts"""
+    cur.execute(
+        "DELETE FROM sync_tombstones WHERE deleted_at < CURRENT_TIMESTAMP - %s",
+        (SYNC_TOMBSTONE_RETENTION,)
+    )
+    return cur.rowcount
+
+def purge_item_operations(cur):
+    """Drop undo snapshots once they can no longer be used"""
+    cur.execute(
+        "DELETE FROM item_operations WHERE created_at < CURRENT_TIMESTAMP - %s",
+        (UNDO_WINDOW,)
+    )
+    return cur.rowcount
+
+def purge_guest_users(cur):
+    """Delete guest accounts (and, by cascade, their data) once inactive longer than GUEST_INACTIVITY"""
+    cur.execute("""
+        DELETE FROM users u
+        WHERE u.is_guest
+          AND COALESCE(
+              (SELECT MAX(s.last_seen_at) FROM user_sessions s WHERE s.user_id = u.id),
+              u.created_at
+          ) < CURRENT_TIMESTAMP - %s
+    """, (GUEST_INACTIVITY,))
+    return cur.rowcount
+
+background_jobs = BackgroundJobs(get_db_connection)
+background_jobs.register('purge trashed items', purge_trashed_items)
+background_jobs.register('purge webhook deliveries', purge_webhook_deliveries)
+background_jobs.register('purge item operations', purge_item_operations)
+background_jobs.register('purge sync tombstones', purge_sync_tombstones)
+background_jobs.register('purge guest users', purge_guest_users)
+
+# Digests commit per user so emails go out only once their send is recorded
+digest_jobs = ConnectionJobs(get_db_connection, name='digests')
+if os.getenv('DIGEST_ENABLED', 'true').lower() == 'true':
+    digest_jobs.register('send digests', send_digests)
+
+# Webhooks are delivered on their own, shorter cycle
+# Deliveries are claimed with SKIP LOCKED, so every worker can run this without an advisory lock
+webhook_jobs = ConnectionJobs(get_db_connection, name='webhooks')
+webhook_jobs.register('deliver webhooks', webhook_dispatcher.deliver_pending)
+
+if os.getenv('BACKGROUND_JOBS_ENABLED', 'true').lower() == 'true':
+    background_jobs.start(interval=int(os.getenv('BACKGROUND_JOBS_INTERVAL_SECONDS', 3600)))
+    digest_jobs.start(interval=int(os.getenv('BACKGROUND_JOBS_INTERVAL_SECONDS', 3600)))
+    webhook_jobs.start(interval=float(os.getenv('WEBHOOK_DELIVERY_INTERVAL_SECONDS', 10)))
+
+# API documentation (/api/openapi.json and /api/docs)
+init_api_docs(app, {
+    'UserRegistrationInput': UserRegistrationSchema,
+    'UserLoginInput': UserLoginSchema,
+    'ForgotPasswordInput': ForgotPasswordSchema,
+    'ResetPasswordInput': ResetPasswordSchema,
+    'ShoppingListItemInput': ShoppingListItemSchema,
+    'ShoppingListInput': ShoppingListSchema,
+    'ListGroupInput': ListGroupSchema,
+    'ListGroupAssignmentInput': ListGroupAssignmentSchema,
+    'ListMergeInput': ListMergeSchema,
+    'ListInviteInput': ListInviteSchema,
+    'UserPreferencesInput': UserPreferencesSchema,
+    'ListSharingInput': ListSharingSchema,
+    'ShareLinkInput': ShareLinkSchema,
+    'AnnouncementInput': AnnouncementSchema,
+    'AccountDeletionInput': AccountDeletionSchema,
+    'FavoriteItemInput': FavoriteItemSchema,
+    'NotificationSnoozeInput': NotificationSnoozeSchema,
+    'CategoryMergeInput': CategoryMergeSchema,
+    'CategoryCompletionInput': CategoryCompletionSchema,
+    'CategoryColorsInput': CategoryColorsSchema,
+    'ListImportInput': ListImportSchema,
+    'ListBatchInput': ListBatchSchema,
+    'ItemBulkInput': ItemBulkSchema,
+    'ItemBulkUpdateInput': ItemBulkUpdateSchema,
+    'WebhookInput': WebhookSchema
+})
+
 if __name__ == '__main__':
     app.run(host='0.0.0.0', port=int(os.getenv('PORT', 3001)), debug=os.getenv('NODE_ENV') != 'production')
\ No newline at end of file
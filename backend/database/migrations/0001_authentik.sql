@@ -8,8 +8,12 @@ ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_provider VARCHAR(20) DEFAULT 'lo
 ALTER TABLE users ADD COLUMN IF NOT EXISTS linked_at TIMESTAMP NULL;
 ALTER TABLE users ADD COLUMN IF NOT EXISTS last_oidc_login TIMESTAMP NULL;
 
+-- Update existing users to ensure they have valid auth_provider
+UPDATE users SET auth_provider = 'local' WHERE auth_provider IS NULL;
+
 -- Add constraint to ensure valid auth_provider values
-ALTER TABLE users ADD CONSTRAINT IF NOT EXISTS chk_auth_provider 
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_auth_provider;
+ALTER TABLE users ADD CONSTRAINT chk_auth_provider 
     CHECK (auth_provider IN ('local', 'authentik', 'both'));
 
 -- Create indexes for performance
@@ -24,6 +28,7 @@ COMMENT ON COLUMN users.last_oidc_login IS 'Last successful OIDC authentication
 
 -- Allow password_hash to be NULL for Authentik-only users
 ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_password_or_authentik;
 ALTER TABLE users ADD CONSTRAINT chk_password_or_authentik 
     CHECK (
         (auth_provider = 'authentik' AND password_hash IS NULL) OR
@@ -46,6 +51,3 @@ CREATE TABLE IF NOT EXISTS auth_audit (
 
 CREATE INDEX IF NOT EXISTS idx_auth_audit_user_id ON auth_audit(user_id);
 CREATE INDEX IF NOT EXISTS idx_auth_audit_created_at ON auth_audit(created_at);
-
--- Update existing users to ensure they have valid auth_provider
-UPDATE users SET auth_provider = 'local' WHERE auth_provider IS NULL;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- Migration: Add item change history
+-- Date: 2026-10-16
+-- Description: Records who created, changed or deleted each shopping list item
+
+CREATE TABLE IF NOT EXISTS item_history (
+    id SERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL, -- No FK so history survives item deletion
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    change_type VARCHAR(20) NOT NULL, -- 'created', 'updated', 'deleted'
+    changes JSONB NOT NULL DEFAULT '{}', -- {field: {old, new}}
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_history_item ON item_history(list_id, item_id, created_at DESC);
+
+COMMENT ON TABLE item_history IS 'Audit log of item changes, written in the same transaction as the change';
@@ -0,0 +1,17 @@
+-- Migration: Track who added and completed each item
+-- Date: 2026-10-16
+-- Description: Adds created_by/completed_by user references to shopping_list_items
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS completed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN shopping_list_items.created_by IS 'User who added the item';
+COMMENT ON COLUMN shopping_list_items.completed_by IS 'User who marked the item completed, NULL while uncompleted';
+
+-- Backfill existing items with the list owner as creator (without touching updated_at)
+ALTER TABLE shopping_list_items DISABLE TRIGGER USER;
+UPDATE shopping_list_items sli
+SET created_by = sl.owner_id
+FROM shopping_lists sl
+WHERE sl.id = sli.list_id AND sli.created_by IS NULL;
+ALTER TABLE shopping_list_items ENABLE TRIGGER USER;
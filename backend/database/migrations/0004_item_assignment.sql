@@ -0,0 +1,9 @@
+-- Migration: Assign items to list collaborators
+-- Date: 2026-10-16
+-- Description: Adds an optional assignee to shopping_list_items
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_assigned ON shopping_list_items(assigned_to);
+
+COMMENT ON COLUMN shopping_list_items.assigned_to IS 'Owner or collaborator responsible for buying the item';
@@ -0,0 +1,15 @@
+-- Migration: Recurring items
+-- Date: 2026-10-16
+-- Description: Lets completed staples reappear on the list after a fixed interval
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS recurring BOOLEAN DEFAULT FALSE;
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS recur_interval_days INTEGER;
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS due_date DATE;
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS recurred_from INTEGER REFERENCES shopping_list_items(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_due ON shopping_list_items(list_id, due_date) WHERE completed = FALSE;
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_recurred_from ON shopping_list_items(recurred_from);
+
+COMMENT ON COLUMN shopping_list_items.recur_interval_days IS 'Days until a completed recurring item reappears';
+COMMENT ON COLUMN shopping_list_items.due_date IS 'Date the item is needed by; set automatically for recurring copies';
+COMMENT ON COLUMN shopping_list_items.recurred_from IS 'Completed item this occurrence was scheduled from';
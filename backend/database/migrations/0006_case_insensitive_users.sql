@@ -0,0 +1,12 @@
+-- Migration: Case-insensitive usernames and emails
+-- Date: 2026-10-16
+-- Description: Normalizes stored emails and enforces case-insensitive uniqueness
+-- Note: Accounts that only differ by casing must be merged manually before running this,
+--       otherwise the unique index creation will fail and list the conflicting values.
+
+-- Store emails trimmed and lowercase, usernames trimmed
+UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email));
+UPDATE users SET username = TRIM(username) WHERE username <> TRIM(username);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email));
@@ -0,0 +1,20 @@
+-- Migration: Email verification for local registrations
+-- Date: 2026-10-16
+-- Description: Adds users.email_verified and single-use verification tokens
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the emailed token
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user ON email_verification_tokens(user_id);
+
+-- Existing accounts predate verification; Authentik accounts are verified by the identity provider
+UPDATE users SET email_verified = TRUE WHERE email_verified = FALSE;
+
+COMMENT ON COLUMN users.email_verified IS 'Whether the user confirmed ownership of their email address';
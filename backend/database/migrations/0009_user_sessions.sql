@@ -0,0 +1,19 @@
+-- Migration: Track login sessions
+-- Date: 2026-10-16
+-- Description: Records issued access tokens so users can list and revoke their sessions
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    jti VARCHAR(64) UNIQUE NOT NULL, -- JWT ID of the access token
+    ip_address INET,
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, revoked_at);
+
+COMMENT ON TABLE user_sessions IS 'Issued access tokens; a token whose session is revoked is rejected';
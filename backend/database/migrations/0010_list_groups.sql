@@ -0,0 +1,20 @@
+-- Migration: List groups
+-- Date: 2026-10-16
+-- Description: Per-user groups ("Home", "Work", ...) for organizing shopping lists
+
+CREATE TABLE IF NOT EXISTS list_groups (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(owner_id, name)
+);
+
+-- Deleting a group keeps its lists and just ungroups them
+ALTER TABLE shopping_lists ADD COLUMN IF NOT EXISTS group_id INTEGER REFERENCES list_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_shopping_lists_group ON shopping_lists(group_id);
+
+DROP TRIGGER IF EXISTS update_list_groups_updated_at ON list_groups;
+CREATE TRIGGER update_list_groups_updated_at BEFORE UPDATE ON list_groups FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
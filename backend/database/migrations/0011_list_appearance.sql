@@ -0,0 +1,13 @@
+-- Migration: List colors and icons
+-- Date: 2026-10-16
+-- Description: Optional display metadata for shopping lists
+
+ALTER TABLE shopping_lists ADD COLUMN IF NOT EXISTS color VARCHAR(7);
+ALTER TABLE shopping_lists ADD COLUMN IF NOT EXISTS icon VARCHAR(50);
+
+ALTER TABLE shopping_lists DROP CONSTRAINT IF EXISTS chk_shopping_lists_color;
+ALTER TABLE shopping_lists ADD CONSTRAINT chk_shopping_lists_color
+    CHECK (color IS NULL OR color ~ '^#[0-9A-Fa-f]{6}$');
+
+COMMENT ON COLUMN shopping_lists.color IS 'Display color as #RRGGBB';
+COMMENT ON COLUMN shopping_lists.icon IS 'Short icon slug chosen by the frontend';
@@ -0,0 +1,21 @@
+-- Migration: Item tags
+-- Date: 2026-10-16
+-- Description: Adds per-user tags and an item/tag join table
+
+CREATE TABLE IF NOT EXISTS item_tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS shopping_list_item_tags (
+    item_id INTEGER REFERENCES shopping_list_items(id) ON DELETE CASCADE,
+    tag_id INTEGER REFERENCES item_tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (item_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_item_tags_tag ON shopping_list_item_tags(tag_id);
+
+COMMENT ON TABLE item_tags IS 'Trimmed, lowercased labels scoped to the user who created them';
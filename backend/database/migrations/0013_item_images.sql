@@ -0,0 +1,7 @@
+-- Migration: Item photos
+-- Date: 2026-10-16
+-- Description: Adds an optional reference photo URL to shopping_list_items
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS image_url VARCHAR(2048);
+
+COMMENT ON COLUMN shopping_list_items.image_url IS 'Reference photo (http/https), either external or uploaded through the API';
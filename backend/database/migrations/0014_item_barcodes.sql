@@ -0,0 +1,9 @@
+-- Migration: Item barcodes
+-- Date: 2026-10-16
+-- Description: Adds a scanned barcode to shopping_list_items for lookup-by-barcode
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS barcode VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_barcode ON shopping_list_items(barcode, created_by) WHERE barcode IS NOT NULL;
+
+COMMENT ON COLUMN shopping_list_items.barcode IS 'Scanned product code (EAN/UPC etc.)';
@@ -0,0 +1,17 @@
+-- Migration: Idempotency keys
+-- Date: 2026-10-16
+-- Description: Stores responses for Idempotency-Key requests so retries don't create duplicates
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_path VARCHAR(255) NOT NULL,
+    response_status INTEGER,
+    response_body JSONB, -- NULL until the original request commits
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    UNIQUE(user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(user_id, expires_at);
@@ -0,0 +1,18 @@
+-- Migration: Item versions
+-- Date: 2026-10-16
+-- Description: Adds a version counter to shopping_list_items for optimistic concurrency
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION increment_version_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS increment_shopping_list_items_version ON shopping_list_items;
+CREATE TRIGGER increment_shopping_list_items_version BEFORE UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION increment_version_column();
+
+COMMENT ON COLUMN shopping_list_items.version IS 'Incremented on every update; clients send it back as expected_version';
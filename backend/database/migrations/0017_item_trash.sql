@@ -0,0 +1,9 @@
+-- Migration: Item trash
+-- Date: 2026-10-16
+-- Description: Soft-deletes items into a per-list trash that is purged after a retention window
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_trash ON shopping_list_items(list_id, deleted_at) WHERE deleted_at IS NOT NULL;
+
+COMMENT ON COLUMN shopping_list_items.deleted_at IS 'Set when the item is moved to the trash; NULL for live items';
@@ -0,0 +1,16 @@
+-- Migration: List events
+-- Date: 2026-10-16
+-- Description: Records list-level changes such as renames for the per-list activity feed
+
+CREATE TABLE IF NOT EXISTS list_events (
+    id SERIAL PRIMARY KEY,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    event_type VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_list_events_list ON list_events(list_id, created_at DESC);
+
+COMMENT ON TABLE list_events IS 'List-level changes (e.g. renames) shown in the activity feed alongside item history';
@@ -0,0 +1,7 @@
+-- Migration: Normalize categories
+-- Date: 2026-10-16
+-- Description: Stores item and grocery memory categories trimmed and lowercase so "Dairy" and "dairy" count as one
+
+UPDATE shopping_list_items SET category = LOWER(TRIM(category)) WHERE category <> LOWER(TRIM(category));
+
+UPDATE grocery_memory SET category = LOWER(TRIM(category)) WHERE category <> LOWER(TRIM(category));
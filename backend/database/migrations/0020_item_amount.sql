@@ -0,0 +1,7 @@
+-- Migration: Item amount
+-- Date: 2026-10-16
+-- Description: Adds a free-text amount ("2-3", "to taste") that clients show instead of the numeric quantity
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS amount VARCHAR(50);
+
+COMMENT ON COLUMN shopping_list_items.amount IS 'Free-text amount shown instead of quantity when set; quantity is still used for totals';
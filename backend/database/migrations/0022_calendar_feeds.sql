@@ -0,0 +1,15 @@
+-- Migration: Calendar feeds
+-- Date: 2026-10-16
+-- Description: Token-authenticated iCalendar subscriptions for items with due dates
+
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    UNIQUE(user_id, list_id)
+);
+
+COMMENT ON COLUMN calendar_feeds.token_hash IS 'SHA-256 of the token in the feed URL; the raw token is only shown when the feed is created';
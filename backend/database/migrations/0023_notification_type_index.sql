@@ -0,0 +1,5 @@
+-- Migration: Notification type index
+-- Date: 2026-10-16
+-- Description: Index for filtering a user's notifications by type, newest first
+
+CREATE INDEX IF NOT EXISTS idx_notifications_type ON notifications(user_id, type, created_at DESC);
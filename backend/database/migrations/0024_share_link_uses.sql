@@ -0,0 +1,8 @@
+-- Migration: Share link uses
+-- Date: 2026-10-16
+-- Description: Optional cap on how many users can join a list through its share link
+
+ALTER TABLE shopping_lists ADD COLUMN IF NOT EXISTS share_link_max_uses INTEGER CHECK (share_link_max_uses IS NULL OR share_link_max_uses > 0);
+ALTER TABLE shopping_lists ADD COLUMN IF NOT EXISTS share_link_uses INTEGER NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN shopping_lists.share_link_max_uses IS 'Joins allowed through the current share link; NULL means unlimited';
@@ -0,0 +1,9 @@
+-- Migration: User preferences
+-- Date: 2026-10-16
+-- Description: Timezone and locale on the user profile for localized timestamps
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale VARCHAR(35);
+
+COMMENT ON COLUMN users.timezone IS 'IANA timezone (e.g. Europe/Prague) used to localize timestamps when no ?tz= is given';
+COMMENT ON COLUMN users.locale IS 'BCP 47 language tag (e.g. cs-CZ) for client-side formatting';
@@ -0,0 +1,8 @@
+-- Migration: Admin users
+-- Date: 2026-10-16
+-- Description: Administrator flag and account disabling
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+COMMENT ON COLUMN users.disabled_at IS 'Set by an administrator; disabled users cannot log in and their sessions are revoked';
@@ -0,0 +1,5 @@
+-- Migration: Grocery memory recency index
+-- Date: 2026-10-16
+-- Description: Index for listing a user's remembered items by last use
+
+CREATE INDEX IF NOT EXISTS idx_grocery_memory_recent ON grocery_memory(user_id, last_used DESC);
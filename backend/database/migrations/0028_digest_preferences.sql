@@ -0,0 +1,6 @@
+-- Migration: Digest preferences
+-- Date: 2026-10-16
+-- Description: Opt-in periodic digest of lists with items left
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS digest_last_sent_at TIMESTAMP;
@@ -0,0 +1,17 @@
+-- Migration: Item operations
+-- Date: 2026-10-16
+-- Description: Snapshots of bulk updates and clear-completed so they can be undone for a short time
+
+CREATE TABLE IF NOT EXISTS item_operations (
+    id SERIAL PRIMARY KEY,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    operation VARCHAR(30) NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    undone_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_item_operations_list ON item_operations(list_id, created_at DESC);
+
+COMMENT ON COLUMN item_operations.snapshot IS 'bulk_update: {"items": [{"id", "before": {changed fields}}]}; clear_completed: {"item_ids": [...]}';
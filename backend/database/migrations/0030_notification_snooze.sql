@@ -0,0 +1,5 @@
+-- Migration: Notification snooze
+-- Date: 2026-10-16
+-- Description: Hide a notification until snoozed_until, when it comes back as unread
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP;
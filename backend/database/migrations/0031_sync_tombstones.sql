@@ -0,0 +1,50 @@
+-- Migration: Sync tombstones
+-- Date: 2026-10-16
+-- Description: Records deleted lists, items, shares and notifications so GET /api/sync can report them,
+-- and tracks updated_at on list_shares and notifications
+
+ALTER TABLE list_shares ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+
+DROP TRIGGER IF EXISTS update_list_shares_updated_at ON list_shares;
+CREATE TRIGGER update_list_shares_updated_at BEFORE UPDATE ON list_shares FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+DROP TRIGGER IF EXISTS update_notifications_updated_at ON notifications;
+CREATE TRIGGER update_notifications_updated_at BEFORE UPDATE ON notifications FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id SERIAL PRIMARY KEY,
+    entity VARCHAR(20) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    list_id INTEGER,
+    user_id INTEGER,
+    deleted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_deleted ON sync_tombstones(deleted_at);
+
+CREATE OR REPLACE FUNCTION record_sync_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'shopping_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, user_id) VALUES ('list', OLD.id, OLD.id, OLD.owner_id);
+    ELSIF TG_TABLE_NAME = 'shopping_list_items' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id) VALUES ('item', OLD.id, OLD.list_id);
+    ELSIF TG_TABLE_NAME = 'list_shares' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, user_id) VALUES ('share', OLD.id, OLD.list_id, OLD.user_id);
+    ELSE
+        INSERT INTO sync_tombstones (entity, entity_id, user_id) VALUES ('notification', OLD.id, OLD.user_id);
+    END IF;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS record_shopping_lists_tombstone ON shopping_lists;
+CREATE TRIGGER record_shopping_lists_tombstone AFTER DELETE ON shopping_lists FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+DROP TRIGGER IF EXISTS record_shopping_list_items_tombstone ON shopping_list_items;
+CREATE TRIGGER record_shopping_list_items_tombstone AFTER DELETE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+DROP TRIGGER IF EXISTS record_list_shares_tombstone ON list_shares;
+CREATE TRIGGER record_list_shares_tombstone AFTER DELETE ON list_shares FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+DROP TRIGGER IF EXISTS record_notifications_tombstone ON notifications;
+CREATE TRIGGER record_notifications_tombstone AFTER DELETE ON notifications FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+
+COMMENT ON COLUMN sync_tombstones.user_id IS 'Owner of a deleted list, the collaborator of a deleted share, or the recipient of a deleted notification';
@@ -0,0 +1,18 @@
+-- Migration: Favorite items
+-- Date: 2026-10-16
+-- Description: Per-user curated quick-add items, separate from the automatic grocery memory
+
+CREATE TABLE IF NOT EXISTS favorite_items (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    priority VARCHAR(20) NOT NULL DEFAULT 'low',
+    quantity INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+DROP TRIGGER IF EXISTS update_favorite_items_updated_at ON favorite_items;
+CREATE TRIGGER update_favorite_items_updated_at BEFORE UPDATE ON favorite_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,32 @@
+-- Migration: Item completion time
+-- Date: 2026-10-16
+-- Description: Adds completed_at to shopping_list_items, maintained by a trigger whenever completed changes
+
+ALTER TABLE shopping_list_items ADD COLUMN IF NOT EXISTS completed_at TIMESTAMP;
+
+COMMENT ON COLUMN shopping_list_items.completed_at IS 'When the item was last marked completed, NULL while uncompleted';
+
+-- Backfill completed items with their last update time (without touching updated_at)
+ALTER TABLE shopping_list_items DISABLE TRIGGER USER;
+UPDATE shopping_list_items SET completed_at = updated_at WHERE completed AND completed_at IS NULL;
+ALTER TABLE shopping_list_items ENABLE TRIGGER USER;
+
+CREATE OR REPLACE FUNCTION set_completed_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    -- An explicit completed_at (undo restoring the previous value) is kept
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.completed AND NEW.completed_at IS NULL THEN
+            NEW.completed_at = CURRENT_TIMESTAMP;
+        END IF;
+    ELSIF NEW.completed IS DISTINCT FROM OLD.completed AND NEW.completed_at IS NOT DISTINCT FROM OLD.completed_at THEN
+        NEW.completed_at = CASE WHEN NEW.completed THEN CURRENT_TIMESTAMP END;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS set_shopping_list_items_completed_at ON shopping_list_items;
+CREATE TRIGGER set_shopping_list_items_completed_at BEFORE INSERT OR UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION set_completed_at_column();
+
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_completed_at ON shopping_list_items(list_id, completed_at) WHERE completed_at IS NOT NULL;
@@ -0,0 +1,5 @@
+-- Migration: Trigram matching
+-- Date: 2026-10-16
+-- Description: Enables pg_trgm for typo-tolerant grocery memory search (?fuzzy=true)
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
@@ -0,0 +1,7 @@
+-- Migration: Guest users
+-- Date: 2026-10-16
+-- Description: Throwaway demo accounts (POST /api/auth/guest), purged after inactivity unless upgraded
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_guest BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_guest ON users(created_at) WHERE is_guest;
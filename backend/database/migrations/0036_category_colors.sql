@@ -0,0 +1,11 @@
+-- Migration: Category colors
+-- Date: 2026-10-16
+-- Description: Per-user category -> hex color mapping so items are colored the same on every list
+
+CREATE TABLE IF NOT EXISTS category_colors (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    color VARCHAR(7) NOT NULL, -- #rrggbb
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category)
+);
@@ -0,0 +1,8 @@
+-- Migration: Generic webhook delivery errors
+-- Date: 2026-10-16
+-- Description: Replace raw exception text already stored in the delivery log with a generic reason
+
+UPDATE webhook_deliveries
+SET last_error = 'Request failed'
+WHERE last_error IS NOT NULL
+  AND last_error !~ '^HTTP [0-9]+$';
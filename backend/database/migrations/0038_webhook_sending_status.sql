@@ -0,0 +1,8 @@
+-- Migration: Webhook sending status
+-- Date: 2026-10-16
+-- Description: Deliveries are claimed as 'sending' and committed before the POST, so the due index covers both states
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_pending;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
+
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending until delivered, or failed after the last retry; sending while a worker holds the claim';
@@ -1,24 +1,50 @@
 -- Shopping List Database Schema
 -- Developed with Claude AI using Claude Code
 
+-- Trigram similarity for typo-tolerant grocery memory search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
 -- Create users table
 CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     username VARCHAR(255) UNIQUE NOT NULL,
     email VARCHAR(255) UNIQUE NOT NULL,
     password_hash VARCHAR(255) NOT NULL,
+    email_verified BOOLEAN DEFAULT FALSE,
+    is_admin BOOLEAN NOT NULL DEFAULT FALSE,
+    disabled_at TIMESTAMP, -- set by an administrator; blocks login
+    is_guest BOOLEAN NOT NULL DEFAULT FALSE, -- demo account from POST /api/auth/guest; purged after inactivity
     default_list_id INTEGER REFERENCES shopping_lists(id) ON DELETE SET NULL,
+    timezone VARCHAR(64), -- IANA name, e.g. 'Europe/Prague'
+    locale VARCHAR(35), -- BCP 47 tag, e.g. 'cs-CZ'
+    digest_enabled BOOLEAN NOT NULL DEFAULT FALSE, -- opt-in digest of lists with items left
+    digest_last_sent_at TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create list_groups table (per-user folders for organizing lists)
+CREATE TABLE IF NOT EXISTS list_groups (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(owner_id, name)
+);
+
 -- Create shopping_lists table
 CREATE TABLE IF NOT EXISTS shopping_lists (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL DEFAULT 'My Shopping List',
     owner_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    group_id INTEGER REFERENCES list_groups(id) ON DELETE SET NULL,
+    color VARCHAR(7) CHECK (color IS NULL OR color ~ '^#[0-9A-Fa-f]{6}$'), -- '#RRGGBB'
+    icon VARCHAR(50), -- short icon slug
     is_shared BOOLEAN DEFAULT FALSE,
     share_token VARCHAR(64) UNIQUE,
+    share_link_max_uses INTEGER CHECK (share_link_max_uses IS NULL OR share_link_max_uses > 0), -- NULL: unlimited joins
+    share_link_uses INTEGER NOT NULL DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
@@ -29,14 +55,43 @@ CREATE TABLE IF NOT EXISTS shopping_list_items (
     list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
     name VARCHAR(255) NOT NULL,
     quantity INTEGER NOT NULL DEFAULT 1,
+    amount VARCHAR(50), -- Free-text amount shown instead of quantity ("2-3", "to taste")
     category VARCHAR(100) NOT NULL,
     priority VARCHAR(20) NOT NULL DEFAULT 'low',
     notes TEXT,
     completed BOOLEAN DEFAULT FALSE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    completed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    completed_at TIMESTAMP, -- Set by trigger when completed flips on, cleared when it flips off
+    assigned_to INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    recurring BOOLEAN DEFAULT FALSE,
+    recur_interval_days INTEGER,
+    due_date DATE,
+    recurred_from INTEGER REFERENCES shopping_list_items(id) ON DELETE SET NULL,
+    image_url VARCHAR(2048), -- Reference photo (http/https)
+    barcode VARCHAR(64), -- Scanned product code (EAN/UPC etc.)
+    version INTEGER NOT NULL DEFAULT 1, -- Incremented on every update (optimistic concurrency)
+    deleted_at TIMESTAMP, -- Set when moved to the trash
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create item_tags table (per-user labels, stored lowercase)
+CREATE TABLE IF NOT EXISTS item_tags (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Create shopping_list_item_tags table (items <-> tags)
+CREATE TABLE IF NOT EXISTS shopping_list_item_tags (
+    item_id INTEGER REFERENCES shopping_list_items(id) ON DELETE CASCADE,
+    tag_id INTEGER REFERENCES item_tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (item_id, tag_id)
+);
+
 -- Create grocery_memory table (for autocomplete and suggestions)
 CREATE TABLE IF NOT EXISTS grocery_memory (
     id SERIAL PRIMARY KEY,
@@ -50,6 +105,28 @@ CREATE TABLE IF NOT EXISTS grocery_memory (
     UNIQUE(user_id, name)
 );
 
+-- Create favorite_items table (curated quick-add items, per user)
+CREATE TABLE IF NOT EXISTS favorite_items (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(100) NOT NULL,
+    priority VARCHAR(20) NOT NULL DEFAULT 'low',
+    quantity INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, name)
+);
+
+-- Create category_colors table (per-user category -> hex color)
+CREATE TABLE IF NOT EXISTS category_colors (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    color VARCHAR(7) NOT NULL, -- #rrggbb
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category)
+);
+
 -- Create list_shares table (for shared shopping lists)
 CREATE TABLE IF NOT EXISTS list_shares (
     id SERIAL PRIMARY KEY,
@@ -58,6 +135,7 @@ CREATE TABLE IF NOT EXISTS list_shares (
     permission VARCHAR(20) DEFAULT 'read', -- 'read', 'write', 'admin'
     status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'accepted', 'declined'
     shared_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UNIQUE(list_id, user_id)
 );
 
@@ -70,18 +148,168 @@ CREATE TABLE IF NOT EXISTS notifications (
     message TEXT NOT NULL,
     data JSON, -- Additional data like list_id, inviter_user_id, etc.
     is_read BOOLEAN DEFAULT FALSE,
+    snoozed_until TIMESTAMP, -- hidden from the default list until then
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create email_verification_tokens table (single-use links sent on registration)
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the emailed token
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create password_reset_tokens table (single-use, short-lived reset links)
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the emailed token
+    expires_at TIMESTAMP NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Create user_sessions table (one row per issued access token)
+CREATE TABLE IF NOT EXISTS user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    jti VARCHAR(64) UNIQUE NOT NULL, -- JWT ID of the access token
+    ip_address INET,
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+-- Create item_history table (audit log of item changes)
+CREATE TABLE IF NOT EXISTS item_history (
+    id SERIAL PRIMARY KEY,
+    item_id INTEGER NOT NULL, -- No FK so history survives item deletion
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    change_type VARCHAR(20) NOT NULL, -- 'created', 'updated', 'deleted', 'restored'
+    changes JSONB NOT NULL DEFAULT '{}', -- {field: {old, new}}
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create list_events table (list-level changes shown in the activity feed)
+CREATE TABLE IF NOT EXISTS list_events (
+    id SERIAL PRIMARY KEY,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    event_type VARCHAR(50) NOT NULL, -- 'list_renamed', 'owner_changed'
+    data JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create item_operations table (undo snapshots for bulk updates and clear-completed)
+CREATE TABLE IF NOT EXISTS item_operations (
+    id SERIAL PRIMARY KEY,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    operation VARCHAR(30) NOT NULL, -- 'bulk_update', 'clear_completed'
+    snapshot JSONB NOT NULL, -- changed fields' previous values, or the cleared item ids
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    undone_at TIMESTAMP
+);
+
+-- Create sync_tombstones table (deleted rows reported by GET /api/sync, filled by triggers)
+CREATE TABLE IF NOT EXISTS sync_tombstones (
+    id SERIAL PRIMARY KEY,
+    entity VARCHAR(20) NOT NULL, -- 'list', 'item', 'share', 'notification'
+    entity_id INTEGER NOT NULL, -- No FK, the row is gone
+    list_id INTEGER,
+    user_id INTEGER, -- list owner, share collaborator or notification recipient
+    deleted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create webhooks table (per-user outgoing webhooks for list and item events)
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(128) NOT NULL, -- Key for the HMAC-SHA256 X-Webhook-Signature header
+    events TEXT[] NOT NULL, -- e.g. 'item.created', 'list.deleted'
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create webhook_deliveries table (delivery log, doubling as the send queue)
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INTEGER REFERENCES webhooks(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sending' (claimed by a worker), 'delivered', 'failed'
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_status INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+-- Create calendar_feeds table (token-authenticated iCalendar subscriptions, one per user and list)
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    list_id INTEGER REFERENCES shopping_lists(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token in the feed URL
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    UNIQUE(user_id, list_id)
+);
+
+-- Create idempotency_keys table (responses replayed for retried create requests)
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_path VARCHAR(255) NOT NULL,
+    response_status INTEGER,
+    response_body JSONB, -- NULL until the original request commits
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    UNIQUE(user_id, idempotency_key)
+);
+
 -- Create indexes for better performance
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username_lower ON users(LOWER(username));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email));
+CREATE INDEX IF NOT EXISTS idx_users_guest ON users(created_at) WHERE is_guest;
 CREATE INDEX IF NOT EXISTS idx_shopping_lists_owner ON shopping_lists(owner_id);
+CREATE INDEX IF NOT EXISTS idx_shopping_lists_group ON shopping_lists(group_id);
 CREATE INDEX IF NOT EXISTS idx_shopping_list_items_list ON shopping_list_items(list_id);
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_trash ON shopping_list_items(list_id, deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_assigned ON shopping_list_items(assigned_to);
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_due ON shopping_list_items(list_id, due_date) WHERE completed = FALSE;
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_completed_at ON shopping_list_items(list_id, completed_at) WHERE completed_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_recurred_from ON shopping_list_items(recurred_from);
+CREATE INDEX IF NOT EXISTS idx_shopping_list_items_barcode ON shopping_list_items(barcode, created_by) WHERE barcode IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_shopping_list_item_tags_tag ON shopping_list_item_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(user_id, expires_at);
+CREATE INDEX IF NOT EXISTS idx_webhooks_user ON webhooks(user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status IN ('pending', 'sending');
 CREATE INDEX IF NOT EXISTS idx_grocery_memory_user ON grocery_memory(user_id);
 CREATE INDEX IF NOT EXISTS idx_grocery_memory_usage ON grocery_memory(user_id, usage_count DESC, last_used DESC);
+CREATE INDEX IF NOT EXISTS idx_grocery_memory_recent ON grocery_memory(user_id, last_used DESC);
 CREATE INDEX IF NOT EXISTS idx_list_shares_list ON list_shares(list_id);
 CREATE INDEX IF NOT EXISTS idx_list_shares_user ON list_shares(user_id);
 CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id);
 CREATE INDEX IF NOT EXISTS idx_notifications_unread ON notifications(user_id, is_read);
+CREATE INDEX IF NOT EXISTS idx_notifications_type ON notifications(user_id, type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user ON email_verification_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, revoked_at);
+CREATE INDEX IF NOT EXISTS idx_item_history_item ON item_history(list_id, item_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_list_events_list ON list_events(list_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_item_operations_list ON item_operations(list_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sync_tombstones_deleted ON sync_tombstones(deleted_at);
 
 -- Create updated_at trigger function
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -95,7 +323,39 @@ $$ language 'plpgsql';
 -- Create triggers for updated_at
 CREATE TRIGGER update_users_updated_at BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE TRIGGER update_shopping_lists_updated_at BEFORE UPDATE ON shopping_lists FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_list_groups_updated_at BEFORE UPDATE ON list_groups FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 CREATE TRIGGER update_shopping_list_items_updated_at BEFORE UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_favorite_items_updated_at BEFORE UPDATE ON favorite_items FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_list_shares_updated_at BEFORE UPDATE ON list_shares FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE TRIGGER update_notifications_updated_at BEFORE UPDATE ON notifications FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Create version trigger function (optimistic concurrency for item edits)
+CREATE OR REPLACE FUNCTION increment_version_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER increment_shopping_list_items_version BEFORE UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION increment_version_column();
+
+-- Create completed_at trigger function (an explicit completed_at, as restored by undo, is kept)
+CREATE OR REPLACE FUNCTION set_completed_at_column()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        IF NEW.completed AND NEW.completed_at IS NULL THEN
+            NEW.completed_at = CURRENT_TIMESTAMP;
+        END IF;
+    ELSIF NEW.completed IS DISTINCT FROM OLD.completed AND NEW.completed_at IS NOT DISTINCT FROM OLD.completed_at THEN
+        NEW.completed_at = CASE WHEN NEW.completed THEN CURRENT_TIMESTAMP END;
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER set_shopping_list_items_completed_at BEFORE INSERT OR UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION set_completed_at_column();
 
 -- Create function to update parent shopping list when items change
 CREATE OR REPLACE FUNCTION update_shopping_list_on_item_change()
@@ -113,4 +373,26 @@ $$ language 'plpgsql';
 -- Create triggers to update shopping list when items are modified
 CREATE TRIGGER update_list_on_item_insert AFTER INSERT ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION update_shopping_list_on_item_change();
 CREATE TRIGGER update_list_on_item_update AFTER UPDATE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION update_shopping_list_on_item_change();
-CREATE TRIGGER update_list_on_item_delete AFTER DELETE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION update_shopping_list_on_item_change();
\ No newline at end of file
+CREATE TRIGGER update_list_on_item_delete AFTER DELETE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION update_shopping_list_on_item_change();
+
+-- Create tombstone function (deleted rows for offline sync)
+CREATE OR REPLACE FUNCTION record_sync_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'shopping_lists' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, user_id) VALUES ('list', OLD.id, OLD.id, OLD.owner_id);
+    ELSIF TG_TABLE_NAME = 'shopping_list_items' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id) VALUES ('item', OLD.id, OLD.list_id);
+    ELSIF TG_TABLE_NAME = 'list_shares' THEN
+        INSERT INTO sync_tombstones (entity, entity_id, list_id, user_id) VALUES ('share', OLD.id, OLD.list_id, OLD.user_id);
+    ELSE
+        INSERT INTO sync_tombstones (entity, entity_id, user_id) VALUES ('notification', OLD.id, OLD.user_id);
+    END IF;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_shopping_lists_tombstone AFTER DELETE ON shopping_lists FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+CREATE TRIGGER record_shopping_list_items_tombstone AFTER DELETE ON shopping_list_items FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+CREATE TRIGGER record_list_shares_tombstone AFTER DELETE ON list_shares FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
+CREATE TRIGGER record_notifications_tombstone AFTER DELETE ON notifications FOR EACH ROW EXECUTE FUNCTION record_sync_tombstone();
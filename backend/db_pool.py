@@ -0,0 +1,131 @@
+#!/usr/bin/env python3
+"""
+Database Connection Pool
+A thread-safe psycopg2 pool with connection lifetime and idle limits
+"""
+
+import os
+import time
+import threading
+from typing import Dict
+
+import psycopg2
+from psycopg2.pool import ThreadedConnectionPool, PoolError
+
+
+class ConnectionPool:
+    """
+    Wraps ThreadedConnectionPool so callers wait for a free connection instead of
+    failing when the pool is exhausted, and recycles connections that are too old
+    or have sat idle too long
+    """
+
+    def __init__(self, dsn: Dict, min_conns: int, max_conns: int, max_lifetime: float,
+                 idle_timeout: float, acquire_timeout: float):
+        self.min_conns = min_conns
+        self.max_conns = max_conns
+        self.max_lifetime = max_lifetime
+        self.idle_timeout = idle_timeout
+        self.acquire_timeout = acquire_timeout
+
+        self._pool = ThreadedConnectionPool(min_conns, max_conns, **dsn)
+        self._slots = threading.BoundedSemaphore(max_conns)
+        self._lock = threading.Lock()
+        self._created_at = {}
+        self._released_at = {}
+        self._opened_total = 0
+        self._closed_total = 0
+
+    def _expired(self, conn, now: float) -> bool:
+        created_at = self._created_at.setdefault(id(conn), now)
+        released_at = self._released_at.get(id(conn))
+        if self.max_lifetime and now - created_at > self.max_lifetime:
+            return True
+        if self.idle_timeout and released_at and now - released_at > self.idle_timeout:
+            return True
+        return False
+
+    def _discard(self, conn) -> None:
+        self._created_at.pop(id(conn), None)
+        self._released_at.pop(id(conn), None)
+        self._pool.putconn(conn, close=True)
+        self._closed_total += 1
+
+    def getconn(self):
+        if not self._slots.acquire(timeout=self.acquire_timeout):
+            raise PoolError(f'No database connection available within {self.acquire_timeout}s')
+
+        try:
+            with self._lock:
+                while True:
+                    conn = self._pool.getconn()
+                    # First checkout of a connection the pool opened
+                    if id(conn) not in self._created_at:
+                        self._opened_total += 1
+                    if conn.closed or self._expired(conn, time.monotonic()):
+                        self._discard(conn)
+                        continue
+                    return conn
+        except Exception:
+            self._slots.release()
+            raise
+
+    def putconn(self, conn) -> None:
+        with self._lock:
+            if conn.closed:
+                self._discard(conn)
+            else:
+                self._released_at[id(conn)] = time.monotonic()
+                self._pool.putconn(conn)
+        self._slots.release()
+
+    def stats(self) -> Dict[str, int]:
+        with self._lock:
+            in_use = len(self._pool._used)
+            idle = len(self._pool._pool)
+            return {
+                'max_conns': self.max_conns,
+                'min_conns': self.min_conns,
+                'open_conns': in_use + idle,
+                'in_use_conns': in_use,
+                'idle_conns': idle,
+                'opened_total': self._opened_total,
+                'closed_total': self._closed_total
+            }
+
+
+def create_connection_pool(dsn: Dict) -> ConnectionPool:
+    """
+    Factory function to create the pool from environment configuration
+    Defaults suit the two gunicorn workers in the Dockerfile (each worker has its own pool)
+    """
+    return ConnectionPool(
+        dsn=dsn,
+        min_conns=int(os.getenv('DB_POOL_MIN_CONNS', 1)),
+        max_conns=int(os.getenv('DB_POOL_MAX_CONNS', 10)),
+        max_lifetime=float(os.getenv('DB_POOL_MAX_LIFETIME_SECONDS', 1800)),
+        idle_timeout=float(os.getenv('DB_POOL_IDLE_TIMEOUT_SECONDS', 300)),
+        acquire_timeout=float(os.getenv('DB_POOL_ACQUIRE_TIMEOUT_SECONDS', 10))
+    )
+
+
+def wait_for_database(dsn: Dict, max_attempts: int, base_delay: float, max_delay: float = 30) -> None:
+    """
+    Block until the database accepts connections, retrying with exponential backoff
+    Raises RuntimeError once max_attempts connections have failed
+    """
+    for attempt in range(1, max_attempts + 1):
+        try:
+            psycopg2.connect(connect_timeout=5, **dsn).close()
+            if attempt > 1:
+                print(f"Database is reachable after {attempt} attempts")
+            return
+        except psycopg2.OperationalError as e:
+            if attempt == max_attempts:
+                raise RuntimeError(
+                    f"Could not connect to the database at {dsn.get('host')}:{dsn.get('port')} "
+                    f"after {max_attempts} attempts: {str(e).strip()}"
+                ) from e
+            delay = min(base_delay * 2 ** (attempt - 1), max_delay)
+            print(f"Database not ready (attempt {attempt}/{max_attempts}): {str(e).strip()}; retrying in {delay:.1f}s")
+            time.sleep(delay)
@@ -0,0 +1,66 @@
+#!/usr/bin/env python3
+"""
+iCalendar Export
+Renders items with due dates as all-day VEVENTs (RFC 5545)
+"""
+
+from datetime import date, datetime, timedelta
+from typing import Iterable, Dict
+
+
+def escape_text(value: str) -> str:
+    """Escape a TEXT property value"""
+    return (value.replace('\\', '\\\\').replace(';', '\\;').replace(',', '\\,')
+            .replace('\r\n', '\\n').replace('\n', '\\n'))
+
+
+def fold_line(line: str) -> str:
+    """Fold content lines longer than 75 octets onto continuation lines"""
+    encoded = line.encode('utf-8')
+    if len(encoded) <= 75:
+        return line
+
+    parts = []
+    current = ''
+    limit = 75
+    for char in line:
+        if len((current + char).encode('utf-8')) > limit:
+            parts.append(current)
+            current = ''
+            limit = 74  # Continuation lines start with a space
+        current += char
+    parts.append(current)
+    return '\r\n '.join(parts)
+
+
+def build_calendar(calendar_name: str, events: Iterable[Dict], host: str) -> str:
+    """
+    Build a VCALENDAR from events with uid, summary, description and date (a datetime.date)
+    UIDs are qualified with host so they stay unique across deployments
+    """
+    stamp = datetime.utcnow().strftime('%Y%m%dT%H%M%SZ')
+    lines = [
+        'BEGIN:VCALENDAR',
+        'VERSION:2.0',
+        'PRODID:-//Shopping List//Due Items//EN',
+        'CALSCALE:GREGORIAN',
+        'METHOD:PUBLISH',
+        f'X-WR-CALNAME:{escape_text(calendar_name)}'
+    ]
+
+    for event in events:
+        day: date = event['date']
+        lines += [
+            'BEGIN:VEVENT',
+            f"UID:{event['uid']}@{host}",
+            f'DTSTAMP:{stamp}',
+            f"DTSTART;VALUE=DATE:{day.strftime('%Y%m%d')}",
+            f"DTEND;VALUE=DATE:{(day + timedelta(days=1)).strftime('%Y%m%d')}",
+            f"SUMMARY:{escape_text(event['summary'])}"
+        ]
+        if event.get('description'):
+            lines.append(f"DESCRIPTION:{escape_text(event['description'])}")
+        lines.append('END:VEVENT')
+
+    lines.append('END:VCALENDAR')
+    return '\r\n'.join(fold_line(line) for line in lines) + '\r\n'
@@ -0,0 +1,75 @@
+#!/usr/bin/env python3
+"""
+Background Jobs
+Periodic maintenance (purging old data) run on a daemon thread inside each worker
+"""
+
+import threading
+import time
+from typing import Callable, List, Tuple
+
+# Arbitrary key for pg_try_advisory_xact_lock so only one worker runs the jobs at a time
+JOBS_LOCK_ID = 727340116
+
+
+class BackgroundJobs:
+    """
+    Runs registered jobs every interval. Each job receives a cursor inside one
+    transaction and returns the number of rows it affected.
+    """
+
+    def __init__(self, get_connection: Callable, lock_id: int = JOBS_LOCK_ID, name: str = 'jobs'):
+        self.get_connection = get_connection
+        self.lock_id = lock_id
+        self.name = name
+        self.jobs: List[Tuple[str, Callable]] = []
+        self._thread = None
+
+    def register(self, name: str, job: Callable) -> None:
+        self.jobs.append((name, job))
+
+    def run_once(self) -> None:
+        with self.get_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute("SELECT pg_try_advisory_xact_lock(%s)", (self.lock_id,))
+                if not cur.fetchone()[0]:
+                    return
+
+                for name, job in self.jobs:
+                    affected = job(cur)
+                    if affected:
+                        print(f"[{self.name}] {name}: {affected} row(s)")
+            conn.commit()
+
+    def start(self, interval: float) -> None:
+        """Start the daemon thread; the first run happens after one interval"""
+        if self._thread:
+            return
+
+        def loop():
+            while True:
+                time.sleep(interval)
+                try:
+                    self.run_once()
+                except Exception as e:
+                    print(f"[{self.name}] Background job error: {e}")
+
+        self._thread = threading.Thread(target=loop, name=f'background-{self.name}', daemon=True)
+        self._thread.start()
+
+
+class ConnectionJobs(BackgroundJobs):
+    """
+    Runs jobs that manage their own transactions, e.g. to make network calls without
+    holding locks. Each job receives get_connection and must be safe to run on several
+    workers at once, so no advisory lock is taken.
+    """
+
+    def __init__(self, get_connection: Callable, name: str = 'jobs'):
+        super().__init__(get_connection, name=name)
+
+    def run_once(self) -> None:
+        for name, job in self.jobs:
+            affected = job(self.get_connection)
+            if affected:
+                print(f"[{self.name}] {name}: {affected} row(s)")
@@ -0,0 +1,83 @@
+#!/usr/bin/env python3
+"""
+Outgoing Email Delivery
+Pluggable mailers so the API can send account emails without tests sending real mail
+"""
+
+import os
+import smtplib
+from email.message import EmailMessage
+from typing import List, Optional
+
+
+class Mailer:
+    """
+    Base mailer interface
+    Implementations deliver a plain-text message to a single recipient
+    """
+
+    def send(self, to: str, subject: str, body: str) -> None:
+        raise NotImplementedError
+
+
+class ConsoleMailer(Mailer):
+    """Prints emails to stdout - the default for development"""
+
+    def send(self, to: str, subject: str, body: str) -> None:
+        print(f"[mail] To: {to}\n[mail] Subject: {subject}\n{body}")
+
+
+class MemoryMailer(Mailer):
+    """Keeps sent emails in memory so tests can inspect them"""
+
+    def __init__(self):
+        self.outbox: List[dict] = []
+
+    def send(self, to: str, subject: str, body: str) -> None:
+        self.outbox.append({'to': to, 'subject': subject, 'body': body})
+
+
+class SMTPMailer(Mailer):
+    """Delivers emails through an SMTP server"""
+
+    def __init__(self, host: str, port: int, sender: str, username: Optional[str] = None,
+                 password: Optional[str] = None, use_tls: bool = True):
+        self.host = host
+        self.port = port
+        self.sender = sender
+        self.username = username
+        self.password = password
+        self.use_tls = use_tls
+
+    def send(self, to: str, subject: str, body: str) -> None:
+        message = EmailMessage()
+        message['From'] = self.sender
+        message['To'] = to
+        message['Subject'] = subject
+        message.set_content(body)
+
+        with smtplib.SMTP(self.host, self.port, timeout=10) as smtp:
+            if self.use_tls:
+                smtp.starttls()
+            if self.username:
+                smtp.login(self.username, self.password or '')
+            smtp.send_message(message)
+
+
+def create_mailer() -> Mailer:
+    """
+    Factory function to create a mailer from environment configuration
+    Falls back to printing emails when no SMTP host is configured
+    """
+    host = os.getenv('SMTP_HOST')
+    if not host:
+        return ConsoleMailer()
+
+    return SMTPMailer(
+        host=host,
+        port=int(os.getenv('SMTP_PORT', 587)),
+        sender=os.getenv('SMTP_FROM', 'no-reply@localhost'),
+        username=os.getenv('SMTP_USERNAME'),
+        password=os.getenv('SMTP_PASSWORD'),
+        use_tls=os.getenv('SMTP_USE_TLS', 'true').lower() == 'true'
+    )
@@ -0,0 +1,102 @@
+#!/usr/bin/env python3
+"""
+Prometheus Metrics
+HTTP request and database pool metrics exposed at /metrics
+"""
+
+import os
+import time
+from flask import Flask, Response, request, g
+from prometheus_client import (
+    Counter, Histogram, CollectorRegistry, REGISTRY, generate_latest, CONTENT_TYPE_LATEST
+)
+from prometheus_client import multiprocess
+from prometheus_client.core import GaugeMetricFamily, CounterMetricFamily
+
+HTTP_REQUESTS = Counter(
+    'http_requests_total', 'HTTP requests handled',
+    ['method', 'route', 'status']
+)
+HTTP_REQUEST_DURATION = Histogram(
+    'http_request_duration_seconds', 'HTTP request latency',
+    ['method', 'route']
+)
+DB_CONNECTION_ERRORS = Counter(
+    'db_connection_errors_total', 'Database connection checkouts that failed'
+)
+DB_CONNECTION_WAIT = Histogram(
+    'db_connection_wait_seconds', 'Time spent waiting for a pooled database connection'
+)
+
+
+class PoolCollector:
+    """Reports connection pool statistics at scrape time"""
+
+    def __init__(self, stats_fn):
+        self.stats_fn = stats_fn
+
+    def collect(self):
+        stats = self.stats_fn()
+        if not stats:
+            return
+        for key in ('max_conns', 'min_conns', 'open_conns', 'in_use_conns', 'idle_conns'):
+            yield GaugeMetricFamily(f'db_pool_{key}', f"Connection pool {key.replace('_', ' ')}", value=stats[key])
+        yield CounterMetricFamily('db_pool_connections_opened', 'Connections opened by the pool', value=stats['opened_total'])
+        yield CounterMetricFamily('db_pool_connections_closed', 'Connections closed by the pool', value=stats['closed_total'])
+
+
+pool_collector = None
+
+
+def metrics_enabled() -> bool:
+    return os.getenv('METRICS_ENABLED', 'false').lower() == 'true'
+
+
+def register_pool_metrics(stats_fn) -> None:
+    """Expose database pool statistics from stats_fn (returns a dict, or None before the pool exists)"""
+    global pool_collector
+    pool_collector = PoolCollector(stats_fn)
+    if metrics_enabled():
+        REGISTRY.register(pool_collector)
+
+
+def _registry():
+    # Under gunicorn each worker keeps its own counters; aggregate them when multiprocess mode is on.
+    # Pool statistics stay per worker since each worker has its own pool.
+    if os.getenv('PROMETHEUS_MULTIPROC_DIR'):
+        registry = CollectorRegistry()
+        multiprocess.MultiProcessCollector(registry)
+        if pool_collector:
+            registry.register(pool_collector)
+        return registry
+    return REGISTRY
+
+
+def init_metrics(app: Flask) -> None:
+    """
+    Register request instrumentation and the /metrics endpoint
+    Does nothing unless METRICS_ENABLED=true, so the endpoint can be kept internal
+    """
+    if not metrics_enabled():
+        return
+
+    @app.before_request
+    def start_request_timer():
+        g.request_started_at = time.perf_counter()
+
+    @app.after_request
+    def record_request_metrics(response):
+        # Label by route template rather than raw path to keep cardinality bounded
+        route = request.url_rule.rule if request.url_rule else 'unmatched'
+        if route == '/metrics':
+            return response
+
+        HTTP_REQUESTS.labels(request.method, route, str(response.status_code)).inc()
+        started_at = g.get('request_started_at')
+        if started_at is not None:
+            HTTP_REQUEST_DURATION.labels(request.method, route).observe(time.perf_counter() - started_at)
+        return response
+
+    @app.route('/metrics', methods=['GET'])
+    def metrics():
+        return Response(generate_latest(_registry()), mimetype=CONTENT_TYPE_LATEST)
@@ -0,0 +1,157 @@
+#!/usr/bin/env python3
+"""
+Versioned Database Migrations
+Applies database/migrations/NNNN_name.sql files in order and records them in schema_migrations
+
+Usage:
+    python migrate.py                 # apply pending migrations
+    python migrate.py --status        # list applied and pending migrations
+    python migrate.py --baseline N    # mark migrations up to N as applied without running them
+"""
+
+import os
+import re
+import sys
+import argparse
+from typing import List, Tuple
+
+import psycopg2
+
+MIGRATIONS_DIR = os.path.join(os.path.dirname(os.path.abspath(__file__)), 'database', 'migrations')
+MIGRATION_FILE_PATTERN = re.compile(r'^(\d{4})_(\w+)\.sql$')
+
+# Arbitrary key for pg_advisory_lock so concurrent workers don't migrate at the same time
+MIGRATION_LOCK_ID = 727340115
+
+
+class MigrationError(Exception):
+    """Raised when a migration fails; nothing from that migration is kept"""
+
+
+def discover_migrations() -> List[Tuple[int, str, str]]:
+    """Return (version, name, path) for every migration file, ordered by version"""
+    migrations = []
+    for filename in os.listdir(MIGRATIONS_DIR):
+        match = MIGRATION_FILE_PATTERN.match(filename)
+        if match:
+            migrations.append((int(match.group(1)), match.group(2), os.path.join(MIGRATIONS_DIR, filename)))
+    migrations.sort()
+
+    versions = [version for version, _, _ in migrations]
+    if len(versions) != len(set(versions)):
+        raise MigrationError('Duplicate migration version numbers in ' + MIGRATIONS_DIR)
+    return migrations
+
+
+def ensure_migrations_table(conn) -> None:
+    with conn.cursor() as cur:
+        cur.execute("""
+            CREATE TABLE IF NOT EXISTS schema_migrations (
+                version INTEGER PRIMARY KEY,
+                name VARCHAR(255) NOT NULL,
+                applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+            )
+        """)
+    conn.commit()
+
+
+def applied_versions(conn) -> set:
+    with conn.cursor() as cur:
+        cur.execute("SELECT version FROM schema_migrations")
+        return {row[0] for row in cur.fetchall()}
+
+
+def run_migrations(conn) -> List[str]:
+    """
+    Apply pending migrations, each in its own transaction
+    Returns the applied migration names; raises MigrationError on the first failure
+    """
+    ensure_migrations_table(conn)
+    applied = []
+
+    with conn.cursor() as cur:
+        cur.execute("SELECT pg_advisory_lock(%s)", (MIGRATION_LOCK_ID,))
+    try:
+        done = applied_versions(conn)
+        conn.commit()
+
+        for version, name, path in discover_migrations():
+            if version in done:
+                continue
+
+            with open(path) as migration_file:
+                sql = migration_file.read()
+
+            try:
+                with conn.cursor() as cur:
+                    cur.execute(sql)
+                    cur.execute(
+                        "INSERT INTO schema_migrations (version, name) VALUES (%s, %s)",
+                        (version, name)
+                    )
+                conn.commit()
+            except psycopg2.Error as e:
+                conn.rollback()
+                raise MigrationError(f"Migration {version:04d}_{name} failed: {str(e).strip()}") from e
+
+            print(f"Applied migration {version:04d}_{name}")
+            applied.append(f"{version:04d}_{name}")
+    finally:
+        with conn.cursor() as cur:
+            cur.execute("SELECT pg_advisory_unlock(%s)", (MIGRATION_LOCK_ID,))
+        conn.commit()
+
+    return applied
+
+
+def baseline(conn, up_to_version: int) -> None:
+    """Record migrations up to a version as applied, for databases migrated by hand"""
+    ensure_migrations_table(conn)
+    with conn.cursor() as cur:
+        for version, name, _ in discover_migrations():
+            if version <= up_to_version:
+                cur.execute("""
+                    INSERT INTO schema_migrations (version, name) VALUES (%s, %s)
+                    ON CONFLICT (version) DO NOTHING
+                """, (version, name))
+    conn.commit()
+
+
+def main() -> int:
+    from dotenv import load_dotenv
+    load_dotenv()
+
+    parser = argparse.ArgumentParser(description='Apply versioned database migrations')
+    parser.add_argument('--status', action='store_true', help='list applied and pending migrations')
+    parser.add_argument('--baseline', type=int, metavar='N', help='mark migrations up to N as applied')
+    args = parser.parse_args()
+
+    conn = psycopg2.connect(
+        host=os.getenv('DB_HOST', 'postgres'),
+        port=int(os.getenv('DB_PORT', 5432)),
+        database=os.getenv('DB_NAME', 'shopping_list'),
+        user=os.getenv('DB_USER', 'shopping_user'),
+        password=os.getenv('DB_PASSWORD', 'shopping_password')
+    )
+    try:
+        if args.baseline is not None:
+            baseline(conn, args.baseline)
+            print(f"Marked migrations up to {args.baseline:04d} as applied")
+        elif args.status:
+            ensure_migrations_table(conn)
+            done = applied_versions(conn)
+            for version, name, _ in discover_migrations():
+                print(f"{'applied' if version in done else 'pending'}  {version:04d}_{name}")
+        else:
+            applied = run_migrations(conn)
+            print(f"Applied {len(applied)} migration(s)" if applied else 'Database is up to date')
+    except MigrationError as e:
+        print(e, file=sys.stderr)
+        return 1
+    finally:
+        conn.close()
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
@@ -0,0 +1,572 @@
+#!/usr/bin/env python3
+"""
+OpenAPI Documentation
+Builds an OpenAPI 3 spec from the Flask routes and marshmallow schemas,
+served at /api/openapi.json with a Swagger UI at /api/docs
+"""
+
+import os
+import re
+from typing import Dict, Type
+from flask import Flask, jsonify, send_from_directory
+from marshmallow import Schema, fields
+from apispec import APISpec
+from apispec.ext.marshmallow import MarshmallowPlugin
+from swagger_ui_bundle import swagger_ui_path
+
+
+# Response models (mirror the columns the handlers return)
+class UserSchema(Schema):
+    id = fields.Int()
+    username = fields.Str()
+    email = fields.Str()
+    email_verified = fields.Bool()
+    is_admin = fields.Bool()
+    is_guest = fields.Bool()
+    timezone = fields.Str(allow_none=True)
+    locale = fields.Str(allow_none=True)
+    digest_enabled = fields.Bool()
+    created_at = fields.DateTime()
+
+
+class ItemSchema(Schema):
+    id = fields.Int()
+    name = fields.Str()
+    quantity = fields.Int()
+    amount = fields.Str(allow_none=True)
+    category = fields.Str()
+    priority = fields.Str()
+    notes = fields.Str()
+    completed = fields.Bool()
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+    created_by = fields.Int(allow_none=True)
+    created_by_username = fields.Str(allow_none=True)
+    completed_by = fields.Int(allow_none=True)
+    completed_by_username = fields.Str(allow_none=True)
+    completed_at = fields.DateTime(allow_none=True)
+    assigned_to = fields.Int(allow_none=True)
+    assigned_to_username = fields.Str(allow_none=True)
+    recurring = fields.Bool()
+    recur_interval_days = fields.Int(allow_none=True)
+    due_date = fields.Date(allow_none=True)
+    recurred_from = fields.Int(allow_none=True)
+    image_url = fields.Str(allow_none=True)
+    barcode = fields.Str(allow_none=True)
+    version = fields.Int()
+    tags = fields.List(fields.Str())
+
+
+class ShoppingListOutputSchema(Schema):
+    id = fields.Int()
+    name = fields.Str()
+    color = fields.Str(allow_none=True)
+    icon = fields.Str(allow_none=True)
+    group_id = fields.Int(allow_none=True)
+    is_shared = fields.Bool()
+    is_owner = fields.Bool()
+    owner_username = fields.Str()
+    user_permission = fields.Str()
+    can_write = fields.Bool(metadata={'description': 'Whether the current user can add, edit and complete items'})
+    share_count = fields.Int()
+    shared_with = fields.List(fields.Dict(), metadata={'description': 'username and permission of each collaborator (owners and admins only)'})
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+    last_activity_at = fields.DateTime(metadata={'description': 'Latest change to the list or any of its items (list index only)'})
+    items = fields.List(fields.Nested(ItemSchema))
+
+
+class ListGroupOutputSchema(Schema):
+    id = fields.Int()
+    name = fields.Str()
+    list_count = fields.Int()
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+
+
+class ItemHistorySchema(Schema):
+    id = fields.Int()
+    change_type = fields.Str()
+    changes = fields.Dict()
+    user_id = fields.Int(allow_none=True)
+    username = fields.Str(allow_none=True)
+    created_at = fields.DateTime()
+
+
+class NotificationSchema(Schema):
+    id = fields.Int()
+    type = fields.Str()
+    title = fields.Str()
+    message = fields.Str()
+    data = fields.Dict(allow_none=True)
+    is_read = fields.Bool()
+    snoozed_until = fields.DateTime(allow_none=True)
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+
+
+class ShareSchema(Schema):
+    id = fields.Int()
+    user_id = fields.Int()
+    username = fields.Str()
+    permission = fields.Str()
+    status = fields.Str()
+    shared_at = fields.DateTime()
+    # Only in GET /api/sync
+    list_id = fields.Int()
+    updated_at = fields.DateTime()
+
+
+class GroceryMemorySchema(Schema):
+    name = fields.Str()
+    category = fields.Str()
+    priority = fields.Str()
+    usage_count = fields.Int()
+    last_used = fields.DateTime()
+    last_used_local = fields.Str(metadata={'description': 'last_used in the requested or profile timezone, when one is set'})
+    similarity = fields.Float(metadata={'description': 'Trigram similarity to the search, only with fuzzy=true'})
+
+
+class FavoriteItemSchema(Schema):
+    id = fields.Int()
+    name = fields.Str()
+    category = fields.Str()
+    priority = fields.Str()
+    quantity = fields.Int()
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+
+
+class SessionSchema(Schema):
+    id = fields.Int()
+    ip_address = fields.Str(allow_none=True)
+    user_agent = fields.Str(allow_none=True)
+    created_at = fields.DateTime()
+    last_seen_at = fields.DateTime()
+    expires_at = fields.DateTime()
+    is_current = fields.Bool()
+
+
+class WebhookOutputSchema(Schema):
+    id = fields.Int()
+    url = fields.Str()
+    events = fields.List(fields.Str())
+    enabled = fields.Bool()
+    secret = fields.Str(metadata={'description': 'Only returned when the webhook is created'})
+    created_at = fields.DateTime()
+    updated_at = fields.DateTime()
+
+
+class ValidationErrorDetailSchema(Schema):
+    field = fields.Str()
+    rule = fields.Str()
+    message = fields.Str()
+
+
+class ErrorSchema(Schema):
+    error = fields.Str()
+    errors = fields.List(fields.Nested(ValidationErrorDetailSchema))
+
+
+RESPONSE_MODELS = {
+    'User': UserSchema,
+    'Item': ItemSchema,
+    'ShoppingList': ShoppingListOutputSchema,
+    'ListGroup': ListGroupOutputSchema,
+    'ItemHistory': ItemHistorySchema,
+    'Notification': NotificationSchema,
+    'Share': ShareSchema,
+    'GroceryMemory': GroceryMemorySchema,
+    'FavoriteItem': FavoriteItemSchema,
+    'Session': SessionSchema,
+    'Webhook': WebhookOutputSchema,
+    'Error': ErrorSchema
+}
+
+
+def ref(name):
+    return {'$ref': f'#/components/schemas/{name}'}
+
+
+def obj(**properties):
+    return {'type': 'object', 'properties': properties}
+
+
+def array(items):
+    return {'type': 'array', 'items': items}
+
+
+STRING = {'type': 'string'}
+INTEGER = {'type': 'integer'}
+BOOLEAN = {'type': 'boolean'}
+MESSAGE = obj(message=STRING)
+TOKEN = obj(message=STRING, user=ref('User'), token=STRING)
+STATS = obj(stats={'type': 'object', 'additionalProperties': {'type': 'number'}})
+
+# Documentation for each view function: tag, summary, request body, success response and query parameters.
+# Request bodies name the input schemas registered by init_api_docs; routes missing here get a generic entry.
+OPERATIONS = {
+    'health_check': {'tag': 'Health', 'summary': 'Service health', 'public': True, 'response': obj(status=STRING)},
+    'get_server_time': {'tag': 'Health', 'summary': 'Server time (RFC 3339 and epoch milliseconds)', 'public': True,
+                        'response': obj(time={'type': 'string', 'format': 'date-time'}, epoch_ms=INTEGER)},
+
+    'register': {'tag': 'Auth', 'summary': 'Register a new user', 'public': True,
+                 'body': 'UserRegistrationInput', 'status': 201, 'response': TOKEN},
+    'login': {'tag': 'Auth', 'summary': 'Log in with username or email', 'public': True,
+              'body': 'UserLoginInput', 'response': TOKEN},
+    'create_guest': {'tag': 'Auth', 'summary': 'Create a temporary guest account (when guest mode is enabled)', 'public': True,
+                     'status': 201, 'response': TOKEN},
+    'upgrade_guest': {'tag': 'Auth', 'summary': 'Turn a guest account into a regular one',
+                      'body': 'UserRegistrationInput', 'response': TOKEN},
+    'get_jwks': {'tag': 'Auth', 'summary': 'Public signing keys (RS256 only)', 'public': True,
+                 'response': obj(keys=array({'type': 'object'}))},
+    'logout': {'tag': 'Auth', 'summary': 'End the current session', 'response': MESSAGE},
+    'get_current_user': {'tag': 'Auth', 'summary': 'Current user', 'response': obj(user=ref('User'))},
+    'update_current_user': {'tag': 'Auth', 'summary': "Set the current user's timezone, locale and digest opt-in", 'body': 'UserPreferencesInput',
+                            'response': obj(message=STRING, user=ref('User'))},
+    'delete_current_user': {'tag': 'Auth', 'summary': 'Delete your account, handing shared lists to an admin collaborator',
+                            'body': 'AccountDeletionInput',
+                            'response': obj(message=STRING, transferred_lists=array(INTEGER), deleted_shared_lists=array(INTEGER))},
+    'verify_email': {'tag': 'Auth', 'summary': 'Confirm an email address', 'public': True,
+                     'query': {'token': STRING}, 'response': obj(message=STRING, user=ref('User'))},
+    'resend_verification_email': {'tag': 'Auth', 'summary': 'Send a new verification email', 'response': MESSAGE},
+    'forgot_password': {'tag': 'Auth', 'summary': 'Email a password reset link', 'public': True,
+                        'body': 'ForgotPasswordInput', 'response': MESSAGE},
+    'reset_password': {'tag': 'Auth', 'summary': 'Set a new password with a reset token', 'public': True,
+                       'body': 'ResetPasswordInput', 'response': MESSAGE},
+    'get_sessions': {'tag': 'Auth', 'summary': 'Active login sessions',
+                     'response': obj(sessions=array(ref('Session')))},
+    'revoke_session': {'tag': 'Auth', 'summary': 'Revoke a session', 'response': MESSAGE},
+    'revoke_all_sessions': {'tag': 'Auth', 'summary': 'Log out everywhere',
+                            'response': obj(message=STRING, revoked_count=INTEGER)},
+    'export_current_user': {'tag': 'Auth', 'summary': "Download the user's own data as a JSON attachment",
+                            'response': obj(version=INTEGER, exported_at={'type': 'string', 'format': 'date-time'}, user=ref('User'),
+                                            lists=array(ref('ShoppingList')), items=array(ref('Item')),
+                                            list_groups=array(obj()), shares=array(obj()),
+                                            notifications=array(ref('Notification')),
+                                            grocery_memory=array(ref('GroceryMemory')),
+                                            favorites=array(ref('FavoriteItem')),
+                                            category_colors=array(obj(category=STRING, color=STRING)), webhooks=array(obj()))},
+
+    'get_shopping_lists': {'tag': 'Lists', 'summary': "User's shopping lists",
+                           'query': {'group_id': INTEGER, 'q': STRING, 'shared': BOOLEAN,
+                                     'sort': {'type': 'string', 'enum': ['name', 'created_at', 'updated_at', 'last_activity_at']},
+                                     'order': {'type': 'string', 'enum': ['asc', 'desc']}, 'limit': INTEGER, 'offset': INTEGER},
+                           'response': obj(lists=array(ref('ShoppingList')), total=INTEGER, limit=INTEGER, offset=INTEGER)},
+    'create_shopping_list': {'tag': 'Lists', 'summary': 'Create a shopping list', 'body': 'ShoppingListInput',
+                             'status': 201, 'response': obj(message=STRING, list=ref('ShoppingList'))},
+    'get_shopping_lists_batch': {'tag': 'Lists', 'summary': 'Several lists with their items in one call',
+                                 'body': 'ListBatchInput', 'response': obj(lists=array(ref('ShoppingList')))},
+    'get_shopping_list': {'tag': 'Lists', 'summary': 'A list with its items', 'response': obj(list=ref('ShoppingList'))},
+    'update_shopping_list': {'tag': 'Lists', 'summary': "Update a list's name, color or icon", 'body': 'ShoppingListInput',
+                             'response': obj(message=STRING, list=ref('ShoppingList'))},
+    'delete_shopping_list': {'tag': 'Lists', 'summary': 'Delete a list', 'response': MESSAGE},
+    'import_shopping_list': {'tag': 'Lists', 'summary': 'Restore a list from a data export as a new list',
+                             'body': 'ListImportInput', 'query': {'preserve': BOOLEAN}, 'status': 201,
+                             'response': obj(message=STRING, list=ref('ShoppingList'), imported_count=INTEGER)},
+    'merge_shopping_lists': {'tag': 'Lists', 'summary': "Copy another list's items into this one", 'body': 'ListMergeInput',
+                             'response': obj(message=STRING, copied_count=INTEGER, merged_count=INTEGER, source_deleted=BOOLEAN)},
+    'set_list_group': {'tag': 'List Groups', 'summary': 'Move a list into a group', 'body': 'ListGroupAssignmentInput',
+                       'response': obj(message=STRING, list=ref('ShoppingList'))},
+    'set_default_list': {'tag': 'Lists', 'summary': 'Set the default list', 'body': obj(list_id=INTEGER),
+                         'response': obj(message=STRING, default_list_id=INTEGER)},
+    'get_default_list': {'tag': 'Lists', 'summary': 'The default list',
+                         'response': obj(default_list_id=INTEGER, default_list=ref('ShoppingList'))},
+    'get_stats_overview': {'tag': 'Lists', 'summary': 'Totals and completion progress', 'response': STATS},
+
+    'get_list_groups': {'tag': 'List Groups', 'summary': "User's list groups", 'response': obj(groups=array(ref('ListGroup')))},
+    'create_list_group': {'tag': 'List Groups', 'summary': 'Create a list group', 'body': 'ListGroupInput',
+                          'status': 201, 'response': obj(message=STRING, group=ref('ListGroup'))},
+    'update_list_group': {'tag': 'List Groups', 'summary': 'Rename a list group', 'body': 'ListGroupInput',
+                          'response': obj(message=STRING, group=ref('ListGroup'))},
+    'delete_list_group': {'tag': 'List Groups', 'summary': 'Delete a list group', 'response': MESSAGE},
+
+    'get_list_items': {'tag': 'Items', 'summary': "A list's items",
+                       'query': {'assigned_to': STRING, 'due': BOOLEAN, 'tag': STRING,
+                                 'completed_since': {'type': 'string', 'format': 'date-time'},
+                                 'sort': {'type': 'string', 'enum': ['created_at', 'category']},
+                                 'group_by': {'type': 'string', 'enum': ['category']}, 'include_colors': BOOLEAN},
+                       'response': obj(items=array(ref('Item')),
+                                       groups=array(obj(category=STRING, items=array(ref('Item')))))},
+    'add_list_item': {'tag': 'Items', 'summary': 'Add an item (409 with the existing item for a duplicate name)',
+                      'body': 'ShoppingListItemInput', 'query': {'force': BOOLEAN},
+                      'status': 201, 'response': obj(message=STRING, item=ref('Item'))},
+    'add_or_increment_list_item': {'tag': 'Items', 'summary': 'Add an item, or add its quantity to a matching uncompleted one',
+                                   'body': 'ShoppingListItemInput', 'status': 201,
+                                   'response': obj(message=STRING, item=ref('Item'), incremented=BOOLEAN)},
+    'add_list_item_from_favorite': {'tag': 'Items', 'summary': 'Add one of your favorite items to a list',
+                                    'status': 201, 'response': obj(message=STRING, item=ref('Item'))},
+    'add_list_items_bulk': {'tag': 'Items', 'summary': 'Add several items in one transaction', 'body': 'ItemBulkInput',
+                            'status': 201, 'response': obj(message=STRING, items=array(ref('Item')))},
+    'update_list_items_bulk': {'tag': 'Items', 'summary': 'Update several items in one transaction (can be undone)',
+                               'body': 'ItemBulkUpdateInput',
+                               'response': obj(message=STRING, items=array(ref('Item')), operation_id=INTEGER)},
+    'duplicate_list_item': {'tag': 'Items', 'summary': 'Copy an item within its list, uncompleted',
+                            'body': 'ShoppingListItemInput', 'status': 201, 'response': obj(message=STRING, item=ref('Item'))},
+    'update_list_item': {'tag': 'Items', 'summary': 'Update an item', 'body': 'ShoppingListItemInput',
+                         'response': obj(message=STRING, item=ref('Item'))},
+    'toggle_list_item': {'tag': 'Items', 'summary': "Toggle an item's completed state",
+                         'response': obj(message=STRING, item=ref('Item'))},
+    'delete_list_item': {'tag': 'Items', 'summary': 'Move an item to the trash', 'response': obj(message=STRING, item=ref('Item'))},
+    'complete_category_items': {'tag': 'Items', 'summary': 'Mark every item in a category completed or not (can be undone)',
+                                'body': 'CategoryCompletionInput',
+                                'response': obj(message=STRING, updated_count=INTEGER, operation_id=INTEGER)},
+    'clear_completed_items': {'tag': 'Items', 'summary': 'Move all completed items to the trash (can be undone)',
+                              'response': obj(message=STRING, cleared_count=INTEGER, operation_id=INTEGER)},
+    'get_trashed_items': {'tag': 'Items', 'summary': "A list's trashed items",
+                          'response': obj(items=array(ref('Item')))},
+    'restore_list_item': {'tag': 'Items', 'summary': 'Restore a trashed item',
+                          'response': obj(message=STRING, item=ref('Item'))},
+    'undo_item_operation': {'tag': 'Items', 'summary': "Undo the list's latest bulk update or clear-completed",
+                            'response': obj(message=STRING, operation=STRING, items=array(ref('Item')))},
+    'upload_item_image': {'tag': 'Items', 'summary': 'Upload a photo for an item',
+                          'body_content': {'multipart/form-data': {'schema': obj(image={'type': 'string', 'format': 'binary'})}},
+                          'response': obj(message=STRING, item=ref('Item'))},
+    'get_uploaded_image': {'tag': 'Items', 'summary': 'An uploaded item photo', 'public': True,
+                           'response_content': {'image/*': {'schema': {'type': 'string', 'format': 'binary'}}}},
+    'create_calendar_feed': {'tag': 'Lists', 'summary': 'Create a calendar subscription URL for items with due dates',
+                             'status': 201, 'response': obj(message=STRING, url=STRING)},
+    'delete_calendar_feed': {'tag': 'Lists', 'summary': "Revoke the user's calendar feed for a list", 'response': MESSAGE},
+    'get_list_calendar': {'tag': 'Lists', 'summary': 'iCalendar feed of uncompleted items with due dates', 'public': True,
+                          'query': {'token': STRING},
+                          'response_content': {'text/calendar': {'schema': {'type': 'string'}}}},
+    'get_list_summary': {'tag': 'Lists', 'summary': 'Item counts for a list, with remaining items per priority',
+                         'response': obj(summary=obj(total_items=INTEGER, completed_items=INTEGER, remaining_items=INTEGER,
+                                                     remaining_by_priority={'type': 'object', 'additionalProperties': INTEGER}))},
+    'get_list_activity': {'tag': 'Lists', 'summary': 'Recent activity on a list', 'query': {'limit': INTEGER, 'offset': INTEGER, 'tz': STRING},
+                          'response': obj(activity=array({'type': 'object'}), limit=INTEGER, offset=INTEGER)},
+    'get_item_history': {'tag': 'Items', 'summary': "An item's change history",
+                         'response': obj(history=array(ref('ItemHistory')))},
+    'get_my_items': {'tag': 'Items', 'summary': 'Uncompleted items assigned to you, grouped by list',
+                     'query': {'priority': STRING, 'list_id': INTEGER},
+                     'response': obj(lists=array(obj(id=INTEGER, name=STRING, color=STRING, icon=STRING,
+                                                     items=array(ref('Item')))))},
+    'get_item_by_barcode': {'tag': 'Items', 'summary': 'Most recent item saved with a barcode',
+                            'query': {'code': STRING}, 'response': obj(item=ref('Item'))},
+
+    'generate_share_link': {'tag': 'Sharing', 'summary': 'Create a public share link', 'body': 'ShareLinkInput',
+                            'response': obj(message=STRING, share_token=STRING, share_url=STRING, list_name=STRING,
+                                            max_uses=INTEGER)},
+    'update_list_sharing': {'tag': 'Sharing', 'summary': 'Turn link sharing on or off', 'body': 'ListSharingInput',
+                            'response': obj(is_shared=BOOLEAN, share_token=STRING, share_url=STRING)},
+    'get_shared_shopping_list': {'tag': 'Sharing', 'summary': 'A list opened through its share link', 'public': True,
+                                 'response': obj(list=ref('ShoppingList'))},
+    'join_shared_list': {'tag': 'Sharing', 'summary': 'Join a list as a collaborator through its share link', 'status': 201,
+                         'response': obj(message=STRING, list_id=INTEGER, permission=STRING)},
+    'toggle_shared_item': {'tag': 'Sharing', 'summary': 'Toggle an item through a share link', 'public': True,
+                           'response': obj(message=STRING, item=ref('Item'))},
+    'search_users': {'tag': 'Sharing', 'summary': 'Search users to invite', 'query': {'q': STRING},
+                     'response': obj(users=array(ref('User')))},
+    'invite_user_to_list': {'tag': 'Sharing', 'summary': 'Invite a user to a list', 'body': 'ListInviteInput',
+                            'response': obj(message=STRING, invited_user=ref('User'))},
+    'get_list_shares': {'tag': 'Sharing', 'summary': "A list's collaborators",
+                        'query': {'status': {'type': 'string', 'enum': ['pending', 'accepted', 'declined']}, 'limit': INTEGER, 'offset': INTEGER},
+                        'response': obj(shares=array(ref('Share')), total=INTEGER,
+                                        counts=obj(pending=INTEGER, accepted=INTEGER, declined=INTEGER),
+                                        limit=INTEGER, offset=INTEGER)},
+    'update_share_permission': {'tag': 'Sharing', 'summary': "Change a collaborator's permission",
+                                'body': obj(permission={'type': 'string', 'enum': ['read', 'write', 'admin']}),
+                                'response': MESSAGE},
+    'remove_share': {'tag': 'Sharing', 'summary': 'Remove a collaborator', 'response': MESSAGE},
+
+    'get_notifications': {'tag': 'Notifications', 'summary': "User's notifications, newest first",
+                          'query': {'q': STRING, 'type': STRING, 'since': {'type': 'string', 'format': 'date-time'},
+                                    'unread_only': BOOLEAN, 'include_snoozed': BOOLEAN, 'limit': INTEGER, 'offset': INTEGER},
+                          'response': obj(notifications=array(ref('Notification')), total=INTEGER, unread_count=INTEGER,
+                                          limit=INTEGER, offset=INTEGER)},
+    'respond_to_notification': {'tag': 'Notifications', 'summary': 'Accept or decline an invitation',
+                                'body': obj(action={'type': 'string', 'enum': ['accept', 'decline']}), 'response': MESSAGE},
+    'mark_notification_read': {'tag': 'Notifications', 'summary': 'Mark a notification read', 'response': MESSAGE},
+    'snooze_notification': {'tag': 'Notifications', 'summary': 'Hide a notification until later, when it returns unread',
+                            'body': 'NotificationSnoozeInput', 'response': obj(notification=ref('Notification'))},
+    'get_unread_notification_count': {'tag': 'Notifications', 'summary': 'Number of unread notifications',
+                                      'response': obj(unread_count=INTEGER)},
+    'get_notification': {'tag': 'Notifications', 'summary': 'A single notification',
+                         'response': obj(notification=ref('Notification'))},
+    'delete_notification': {'tag': 'Notifications', 'summary': 'Delete a notification', 'response': MESSAGE},
+
+    'sync_changes': {'tag': 'Sync', 'summary': 'Everything changed or deleted since a timestamp, for offline clients',
+                     'query': {'since': {'type': 'string', 'format': 'date-time'}},
+                     'response': obj(server_time={'type': 'string', 'format': 'date-time'},
+                                     lists=array(ref('ShoppingList')), items=array(ref('Item')),
+                                     shares=array(ref('Share')), notifications=array(ref('Notification')),
+                                     deleted=obj(lists=array(INTEGER), items=array(INTEGER),
+                                                 shares=array(INTEGER), notifications=array(INTEGER)))},
+
+    'admin_get_users': {'tag': 'Admin', 'summary': 'All users, newest first (administrators only)',
+                        'query': {'q': STRING, 'limit': INTEGER, 'offset': INTEGER},
+                        'response': obj(users=array(ref('User')), total=INTEGER, limit=INTEGER, offset=INTEGER)},
+    'admin_disable_user': {'tag': 'Admin', 'summary': 'Disable a user and revoke their sessions',
+                           'response': obj(message=STRING, user={'type': 'object'})},
+    'admin_enable_user': {'tag': 'Admin', 'summary': 'Re-enable a disabled user', 'response': obj(message=STRING, user={'type': 'object'})},
+    'admin_create_announcement': {'tag': 'Admin', 'summary': 'Send an announcement notification to every user or to user_ids',
+                                  'body': 'AnnouncementInput', 'status': 201, 'response': obj(message=STRING, recipient_count=INTEGER)},
+    'admin_get_stats': {'tag': 'Admin', 'summary': 'Service-wide counts', 'response': obj(stats={'type': 'object'})},
+
+    'get_webhooks': {'tag': 'Webhooks', 'summary': "User's webhooks", 'response': obj(webhooks=array(ref('Webhook')))},
+    'create_webhook': {'tag': 'Webhooks', 'summary': 'Create a webhook (the signing secret is returned once)',
+                       'body': 'WebhookInput', 'status': 201, 'response': obj(message=STRING, webhook=ref('Webhook'))},
+    'update_webhook': {'tag': 'Webhooks', 'summary': "Change a webhook's URL, events, secret or enabled flag",
+                       'body': 'WebhookInput', 'response': obj(message=STRING, webhook=ref('Webhook'))},
+    'delete_webhook': {'tag': 'Webhooks', 'summary': 'Delete a webhook', 'response': MESSAGE},
+    'get_webhook_deliveries': {'tag': 'Webhooks', 'summary': "A webhook's delivery log",
+                               'query': {'limit': INTEGER, 'offset': INTEGER},
+                               'response': obj(deliveries=array({'type': 'object'}), limit=INTEGER, offset=INTEGER)},
+
+    'get_favorite_items': {'tag': 'Favorites', 'summary': "The user's favorite items, by name",
+                           'response': obj(favorites=array(ref('FavoriteItem')))},
+    'create_favorite_item': {'tag': 'Favorites', 'summary': 'Add a favorite item', 'body': 'FavoriteItemInput',
+                             'status': 201, 'response': obj(message=STRING, favorite=ref('FavoriteItem'))},
+    'update_favorite_item': {'tag': 'Favorites', 'summary': 'Change a favorite item (only the fields sent)',
+                             'body': 'FavoriteItemInput', 'response': obj(message=STRING, favorite=ref('FavoriteItem'))},
+    'delete_favorite_item': {'tag': 'Favorites', 'summary': 'Delete a favorite item', 'response': MESSAGE},
+
+    'get_grocery_memory': {'tag': 'Grocery Memory', 'summary': 'Autocomplete suggestions',
+                           'query': {'search': STRING, 'priority': STRING, 'min_frequency': INTEGER,
+                                     'sort': {'type': 'string', 'enum': ['frequency', 'recency']}, 'fuzzy': BOOLEAN,
+                                     'limit': INTEGER, 'tz': STRING},
+                           'response': obj(groceries=array(ref('GroceryMemory')))},
+    'clear_grocery_memory': {'tag': 'Grocery Memory', 'summary': 'Forget remembered items (list items are kept)',
+                             'query': {'category': STRING}, 'response': obj(message=STRING, cleared_count=INTEGER)},
+    'get_category_colors': {'tag': 'Grocery Memory', 'summary': 'Your category colors',
+                            'response': obj(colors={'type': 'object', 'additionalProperties': STRING})},
+    'set_category_colors': {'tag': 'Grocery Memory', 'summary': 'Replace your category colors',
+                            'body': 'CategoryColorsInput',
+                            'response': obj(message=STRING, colors={'type': 'object', 'additionalProperties': STRING})},
+    'get_frequent_groceries': {'tag': 'Grocery Memory', 'summary': 'Frequently used items', 'query': {'limit': INTEGER, 'tz': STRING},
+                               'response': obj(groceries=array(ref('GroceryMemory')))},
+    'get_grocery_categories': {'tag': 'Grocery Memory', 'summary': 'Remembered items per category',
+                               'response': obj(categories=array(obj(category=STRING, item_count=INTEGER, total_usage=INTEGER)))},
+    'merge_grocery_category': {'tag': 'Grocery Memory', 'summary': "Move all of the user's items from one category to another",
+                               'body': 'CategoryMergeInput',
+                               'response': obj(message=STRING, memory_updated=INTEGER, items_updated=INTEGER)},
+    'get_grocery_stats': {'tag': 'Grocery Memory', 'summary': 'Usage statistics', 'response': STATS},
+
+    'oidc_login': {'tag': 'OIDC', 'summary': 'Start an OIDC login', 'public': True,
+                   'response': obj(authorization_url=STRING, state=STRING)},
+    'oidc_callback': {'tag': 'OIDC', 'summary': 'Complete an OIDC login', 'public': True,
+                      'body': obj(code=STRING, state=STRING, use_cookie=BOOLEAN), 'response': TOKEN},
+    'link_oidc_account': {'tag': 'OIDC', 'summary': 'Link the account to the OIDC provider', 'response': MESSAGE},
+    'unlink_oidc_account': {'tag': 'OIDC', 'summary': 'Unlink the OIDC provider', 'response': MESSAGE},
+    'oidc_status': {'tag': 'OIDC', 'summary': 'OIDC link status',
+                    'response': obj(is_linked=BOOLEAN, auth_provider=STRING, linked_at=STRING, last_oidc_login=STRING)}
+}
+
+# Endpoints that are not part of the public API surface
+UNDOCUMENTED_ENDPOINTS = {'static', 'openapi_spec', 'api_docs', 'api_docs_asset', 'metrics'}
+
+# The Swagger UI assets are served from the swagger-ui-bundle package, so viewing the docs needs no CDN
+SWAGGER_UI_HTML = """<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Shopping List API</title>
+    <link rel="stylesheet" href="docs/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="docs/swagger-ui-bundle.js"></script>
+    <script>
+        SwaggerUIBundle({ url: 'openapi.json', dom_id: '#swagger-ui' });
+    </script>
+</body>
+</html>
+"""
+
+
+def api_docs_enabled() -> bool:
+    default = str(os.getenv('NODE_ENV') != 'production')
+    return os.getenv('API_DOCS_ENABLED', default).lower() == 'true'
+
+
+def _operation(endpoint: str, rule, method: str) -> dict:
+    info = OPERATIONS.get(endpoint, {})
+    path_params = re.findall(r'<(?:(\w+)(?:\([^)]*\))?:)?(\w+)>', rule.rule)
+
+    parameters = [
+        {'name': name, 'in': 'path', 'required': True,
+         'schema': INTEGER if converter == 'int' else STRING}
+        for converter, name in path_params
+    ]
+    for name, schema in info.get('query', {}).items():
+        parameters.append({'name': name, 'in': 'query', 'required': False, 'schema': schema})
+
+    operation = {
+        'tags': [info.get('tag', 'Other')],
+        'summary': info.get('summary', endpoint.replace('_', ' ').capitalize()),
+        'operationId': endpoint if len(rule.methods - {'HEAD', 'OPTIONS'}) == 1 else f'{endpoint}_{method}',
+        'parameters': parameters,
+        'responses': {
+            str(info.get('status', 200)): {
+                'description': 'Success',
+                'content': info.get('response_content', {'application/json': {'schema': info.get('response', {'type': 'object'})}})
+            },
+            'default': {'description': 'Error', 'content': {'application/json': {'schema': ref('Error')}}}
+        }
+    }
+
+    body = info.get('body')
+    if body and method in ('post', 'put', 'patch'):
+        schema = ref(body) if isinstance(body, str) else body
+        operation['requestBody'] = {'required': True, 'content': {'application/json': {'schema': schema}}}
+    elif 'body_content' in info:
+        operation['requestBody'] = {'required': True, 'content': info['body_content']}
+
+    if info.get('public'):
+        operation['security'] = []
+
+    return operation
+
+
+def build_spec(app: Flask, request_schemas: Dict[str, Type[Schema]]) -> dict:
+    """Build the OpenAPI document for every route registered on the app"""
+    spec = APISpec(
+        title='Shopping List API',
+        version='1.0.0',
+        openapi_version='3.0.3',
+        plugins=[MarshmallowPlugin()],
+        security=[{'bearerAuth': []}]
+    )
+    spec.components.security_scheme('bearerAuth', {'type': 'http', 'scheme': 'bearer', 'bearerFormat': 'JWT'})
+
+    for name, schema in {**request_schemas, **RESPONSE_MODELS}.items():
+        spec.components.schema(name, schema=schema)
+
+    paths = {}
+    for rule in sorted(app.url_map.iter_rules(), key=lambda r: r.rule):
+        if rule.endpoint in UNDOCUMENTED_ENDPOINTS:
+            continue
+        path = re.sub(r'<(?:[^:>]+:)?(\w+)>', r'{\1}', rule.rule)
+        for method in sorted(rule.methods - {'HEAD', 'OPTIONS'}):
+            paths.setdefault(path, {})[method.lower()] = _operation(rule.endpoint, rule, method.lower())
+
+    for path, operations in paths.items():
+        spec.path(path=path, operations=operations)
+
+    return spec.to_dict()
+
+
+def init_api_docs(app: Flask, request_schemas: Dict[str, Type[Schema]]) -> None:
+    """
+    Register /api/openapi.json and the Swagger UI at /api/docs
+    Enabled by default outside production; set API_DOCS_ENABLED to override
+    """
+    if not api_docs_enabled():
+        return
+
+    cached = {}
+
+    @app.route('/api/openapi.json', methods=['GET'])
+    def openapi_spec():
+        # Routes are all registered by the first request, so the spec only needs building once
+        if 'spec' not in cached:
+            cached['spec'] = build_spec(app, request_schemas)
+        return jsonify(cached['spec'])
+
+    @app.route('/api/docs', methods=['GET'])
+    def api_docs():
+        return SWAGGER_UI_HTML, 200, {'Content-Type': 'text/html; charset=utf-8'}
+
+    @app.route('/api/docs/<path:filename>', methods=['GET'])
+    def api_docs_asset(filename):
+        return send_from_directory(swagger_ui_path, filename)
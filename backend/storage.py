@@ -0,0 +1,45 @@
+#!/usr/bin/env python3
+"""
+Uploaded Image Storage
+Pluggable backends for item photos so uploads can move to an object store later
+"""
+
+import os
+import secrets
+
+
+class ImageStorage:
+    """
+    Base image storage interface
+    Implementations persist an uploaded image and return its public URL
+    """
+
+    def save(self, data: bytes, extension: str) -> str:
+        raise NotImplementedError
+
+
+class LocalImageStorage(ImageStorage):
+    """Writes images to a directory served by the API under /api/uploads"""
+
+    def __init__(self, directory: str, public_url: str):
+        self.directory = directory
+        self.public_url = public_url.rstrip('/')
+        os.makedirs(self.directory, exist_ok=True)
+
+    def save(self, data: bytes, extension: str) -> str:
+        # Random names so uploads can't overwrite each other or be guessed
+        filename = f"{secrets.token_hex(16)}.{extension}"
+        with open(os.path.join(self.directory, filename), 'wb') as f:
+            f.write(data)
+        return f"{self.public_url}/{filename}"
+
+
+def create_image_storage() -> ImageStorage:
+    """
+    Factory function to create image storage from environment configuration
+    """
+    port = os.getenv('PORT', 3001)
+    return LocalImageStorage(
+        directory=os.getenv('IMAGE_UPLOAD_DIR', 'uploads'),
+        public_url=os.getenv('IMAGE_PUBLIC_URL', f'http://localhost:{port}/api/uploads')
+    )
@@ -0,0 +1,168 @@
+"""
+Test fixtures
+The suite drives the Flask app through its test client against a real PostgreSQL
+database (TEST_DB_* settings, shopping_list_test on localhost by default). Tests that
+need the database are skipped when it can't be reached.
+"""
+
+import os
+import sys
+import uuid
+import tempfile
+
+import pytest
+
+BACKEND_DIR = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+sys.path.insert(0, BACKEND_DIR)
+
+# Configuration is read when app is imported, so it has to be in place first
+os.environ.update({
+    'DB_HOST': os.getenv('TEST_DB_HOST', 'localhost'),
+    'DB_PORT': os.getenv('TEST_DB_PORT', '5432'),
+    'DB_NAME': os.getenv('TEST_DB_NAME', 'shopping_list_test'),
+    'DB_USER': os.getenv('TEST_DB_USER', 'shopping_user'),
+    'DB_PASSWORD': os.getenv('TEST_DB_PASSWORD', 'shopping_password'),
+    'JWT_SECRET': 'test-suite-jwt-secret',
+    # The app must import without a database; the schema is set up by the database fixture
+    'DB_CONNECT_MAX_ATTEMPTS': '0',
+    'RUN_MIGRATIONS': 'false',
+    'BACKGROUND_JOBS_ENABLED': 'false',
+    'ADMIN_EMAILS': '',
+    'SMTP_HOST': '',
+    'BCRYPT_ROUNDS': '4',
+    'IMAGE_UPLOAD_DIR': tempfile.mkdtemp(prefix='shopping-list-uploads-'),
+    'CORS_ALLOWED_ORIGINS': 'https://app.example.com,https://*.example.org'
+})
+
+import psycopg2  # noqa: E402
+
+import app as backend  # noqa: E402
+from mailer import MemoryMailer  # noqa: E402
+from migrate import run_migrations  # noqa: E402
+
+
+@pytest.fixture(scope='session')
+def database():
+    """Create the schema on first use and bring it up to date, like a fresh deployment"""
+    try:
+        conn = psycopg2.connect(**backend.DB_CONFIG, connect_timeout=3)
+    except psycopg2.OperationalError as e:
+        pytest.skip(f'Test database unavailable: {e}')
+
+    try:
+        with conn.cursor() as cur:
+            cur.execute("SELECT to_regclass('public.users')")
+            if cur.fetchone()[0] is None:
+                with open(os.path.join(BACKEND_DIR, 'database', 'schema.sql')) as schema:
+                    cur.execute(schema.read())
+        conn.commit()
+        run_migrations(conn)
+    finally:
+        conn.close()
+
+
+@pytest.fixture
+def app_client():
+    """Test client for requests that never reach the database"""
+    return backend.app.test_client()
+
+
+@pytest.fixture
+def client(database, app_client):
+    return app_client
+
+
+@pytest.fixture
+def db(database):
+    """A database cursor for checking what handlers stored"""
+    with backend.get_db_connection() as conn:
+        with conn.cursor(cursor_factory=backend.RealDictCursor) as cur:
+            yield cur
+
+
+@pytest.fixture(autouse=True)
+def outbox(monkeypatch):
+    """Capture outgoing email instead of printing it"""
+    mailer = MemoryMailer()
+    monkeypatch.setattr(backend, 'mailer', mailer)
+    return mailer.outbox
+
+
+def unique_name(prefix='user'):
+    return f'{prefix}{uuid.uuid4().hex[:10]}'
+
+
+def error_fields(response):
+    """Fields named in a 400 validation error response"""
+    assert response.status_code == 400, response.get_json()
+    return {error['field'] for error in response.get_json()['errors']}
+
+
+@pytest.fixture
+def register(client):
+    """Register a user; returns their id, username and auth headers"""
+    def register_user(username=None, email=None, password='secret123'):
+        username = username or unique_name()
+        response = client.post('/api/auth/register', json={
+            'username': username,
+            'email': email or f'{username}@example.com',
+            'password': password
+        })
+        assert response.status_code == 201, response.get_json()
+        body = response.get_json()
+        return {
+            'id': body['user']['id'],
+            'username': body['user']['username'],
+            'email': body['user']['email'],
+            'headers': {'Authorization': f"Bearer {body['token']}"}
+        }
+    return register_user
+
+
+@pytest.fixture
+def create_list(client):
+    """Create an empty list for a registered user; returns its id"""
+    def create(user, name='Test list'):
+        response = client.post('/api/lists', json={'name': name}, headers=user['headers'])
+        assert response.status_code == 201, response.get_json()
+        return response.get_json()['list']['id']
+    return create
+
+
+@pytest.fixture
+def delete_lists(client):
+    """Delete every list a user can see, such as the starter list created at registration"""
+    def delete(user):
+        for shopping_list in client.get('/api/lists', headers=user['headers']).get_json()['lists']:
+            response = client.delete(f"/api/lists/{shopping_list['id']}", headers=user['headers'])
+            assert response.status_code == 200, response.get_json()
+    return delete
+
+
+@pytest.fixture
+def add_item(client):
+    """Add an item to a list; returns the created item"""
+    def add(user, list_id, name=None, category='produce', **fields):
+        response = client.post(f'/api/lists/{list_id}/items', json={
+            'name': name or unique_name('item'), 'category': category, **fields
+        }, headers=user['headers'])
+        assert response.status_code == 201, response.get_json()
+        return response.get_json()['item']
+    return add
+
+
+@pytest.fixture
+def share_list(client):
+    """Invite a user to a list and accept the invitation on their behalf"""
+    def share(owner, member, list_id, permission='write'):
+        response = client.post(f'/api/lists/{list_id}/invite', json={
+            'username': member['username'], 'permission': permission
+        }, headers=owner['headers'])
+        assert response.status_code == 200, response.get_json()
+        
+        notifications = client.get('/api/notifications?type=share_invitation', headers=member['headers'])
+        invitation = next(n for n in notifications.get_json()['notifications'] if n['data']['list_id'] == list_id)
+        response = client.post(f"/api/notifications/{invitation['id']}/respond", json={'action': 'accept'},
+                               headers=member['headers'])
+        assert response.status_code == 200, response.get_json()
+    return share
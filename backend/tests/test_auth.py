@@ -0,0 +1,129 @@
+import time
+from datetime import timedelta
+
+import app as backend
+from conftest import unique_name
+
+
+def test_register_rejects_username_differing_only_in_case(client, register):
+    user = register(username=unique_name('Alice'))
+    
+    response = client.post('/api/auth/register', json={
+        'username': user['username'].lower(),
+        'email': f"other-{user['email']}",
+        'password': 'secret123'
+    })
+    
+    assert response.status_code == 409
+
+
+def test_register_rejects_email_differing_only_in_case(client, register):
+    user = register()
+    
+    response = client.post('/api/auth/register', json={
+        'username': unique_name(),
+        'email': user['email'].upper(),
+        'password': 'secret123'
+    })
+    
+    assert response.status_code == 409
+
+
+def test_register_trims_username_and_lowercases_email(client):
+    username = unique_name('Bob')
+    
+    response = client.post('/api/auth/register', json={
+        'username': f'  {username}  ',
+        'email': f'  {username.upper()}@Example.COM ',
+        'password': 'secret123'
+    })
+    
+    assert response.status_code == 201
+    user = response.get_json()['user']
+    assert user['username'] == username
+    assert user['email'] == f'{username.lower()}@example.com'
+
+
+def test_login_ignores_case(client, register):
+    user = register(username=unique_name('Carol'))
+    
+    for login in (user['username'].upper(), user['email'].upper()):
+        response = client.post('/api/auth/login', json={'login': login, 'password': 'secret123'})
+        assert response.status_code == 200
+        assert response.get_json()['user']['id'] == user['id']
+
+
+def stored_password_hash(db, user_id):
+    db.execute("SELECT password_hash FROM users WHERE id = %s", (user_id,))
+    return db.fetchone()['password_hash']
+
+
+def test_login_upgrades_low_cost_hash(client, register, db, monkeypatch):
+    user = register()
+    assert stored_password_hash(db, user['id']).startswith('$2b$04$')
+    
+    monkeypatch.setattr(backend, 'BCRYPT_ROUNDS', 5)
+    response = client.post('/api/auth/login', json={'login': user['username'], 'password': 'secret123'})
+    
+    assert response.status_code == 200
+    upgraded = stored_password_hash(db, user['id'])
+    assert upgraded.startswith('$2b$05$')
+    assert backend.check_password('secret123', upgraded)
+
+
+def test_login_keeps_current_cost_hash(client, register, db):
+    user = register()
+    original = stored_password_hash(db, user['id'])
+    
+    response = client.post('/api/auth/login', json={'login': user['username'], 'password': 'secret123'})
+    
+    assert response.status_code == 200
+    assert stored_password_hash(db, user['id']) == original
+
+
+def test_session_activity_is_recorded_coarsely(client, register, db):
+    user = register()
+    db.execute("""
+        UPDATE user_sessions SET last_seen_at = CURRENT_TIMESTAMP - INTERVAL '1 hour'
+        WHERE user_id = %s RETURNING last_seen_at
+    """, (user['id'],))
+    stale = db.fetchone()['last_seen_at']
+    db.connection.commit()
+    
+    assert client.get('/api/auth/me', headers=user['headers']).status_code == 200
+    db.execute("SELECT last_seen_at FROM user_sessions WHERE user_id = %s", (user['id'],))
+    refreshed = db.fetchone()['last_seen_at']
+    assert refreshed > stale
+    
+    # Within SESSION_ACTIVITY_INTERVAL the session row isn't written again
+    assert client.get('/api/auth/me', headers=user['headers']).status_code == 200
+    db.execute("SELECT last_seen_at FROM user_sessions WHERE user_id = %s", (user['id'],))
+    assert db.fetchone()['last_seen_at'] == refreshed
+
+
+def test_guest_token_is_not_refreshed_into_a_full_length_token(client, monkeypatch):
+    monkeypatch.setattr(backend, 'GUEST_MODE_ENABLED', True)
+    monkeypatch.setattr(backend, 'GUESTS_PER_HOUR', 1000)
+    created = client.post('/api/auth/guest', json={'use_cookie': False})
+    assert created.status_code == 201
+    
+    response = client.get('/api/auth/me', headers={'Authorization': f"Bearer {created.get_json()['token']}"})
+    
+    assert response.status_code == 200
+    assert 'X-Refreshed-Token' not in response.headers
+
+
+def test_refreshed_token_keeps_its_lifetime(client, register):
+    user = register()
+    now = int(time.time())
+    with backend.app.app_context():
+        # An hour-long token with ten minutes left
+        token = backend.create_access_token(identity=str(user['id']), expires_delta=timedelta(hours=1),
+                                            additional_claims={'iat': now - 50 * 60, 'exp': now + 10 * 60})
+    
+    response = client.get('/api/auth/me', headers={'Authorization': f'Bearer {token}'})
+    
+    assert response.status_code == 200
+    with backend.app.app_context():
+        claims = backend.decode_token(response.headers['X-Refreshed-Token'])
+    assert claims['exp'] - claims['iat'] == 60 * 60
@@ -0,0 +1,45 @@
+import pytest
+
+import app as backend
+
+# conftest sets CORS_ALLOWED_ORIGINS=https://app.example.com,https://*.example.org
+
+
+@pytest.mark.parametrize('origin', [
+    'https://app.example.com',
+    'https://shop.example.org'
+])
+def test_cors_allows_configured_origins(app_client, origin):
+    response = app_client.get('/api/time', headers={'Origin': origin})
+    
+    assert response.headers.get('Access-Control-Allow-Origin') == origin
+
+
+@pytest.mark.parametrize('origin', [
+    'https://evil.example.net',
+    'http://app.example.com',
+    'https://example.org',
+    'https://a.b.example.org',
+    'https://shop.example.org.evil.net'
+])
+def test_cors_rejects_other_origins(app_client, origin):
+    response = app_client.get('/api/time', headers={'Origin': origin})
+    
+    assert 'Access-Control-Allow-Origin' not in response.headers
+
+
+def test_parse_cors_origins_trims_entries_and_compiles_wildcards():
+    origins = backend.parse_cors_origins(' https://a.example.com/ ,, https://*.example.org')
+    
+    assert origins[0] == 'https://a.example.com'
+    assert origins[1].match('https://shop.example.org')
+    assert not origins[1].match('https://example.org')
+
+
+def test_oversized_json_body_is_rejected(app_client):
+    name = 'x' * (backend.MAX_REQUEST_BYTES + 1)
+    
+    response = app_client.post('/api/lists', json={'name': name})
+    
+    assert response.status_code == 413
+    assert response.get_json() == {'error': f'Request body must be at most {backend.MAX_REQUEST_BYTES // 1024} KB'}
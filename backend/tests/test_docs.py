@@ -0,0 +1,20 @@
+def response_properties(spec, path, method, status):
+    schema = spec['paths'][path][method]['responses'][status]['content']['application/json']['schema']
+    return schema['properties']
+
+
+def test_spec_matches_token_and_session_fields(app_client):
+    spec = app_client.get('/api/openapi.json').get_json()
+    
+    assert 'token' in response_properties(spec, '/api/auth/register', 'post', '201')
+    assert 'access_token' not in response_properties(spec, '/api/auth/login', 'post', '200')
+    assert 'is_current' in spec['components']['schemas']['Session']['properties']
+
+
+def test_swagger_ui_is_served_without_a_cdn(app_client):
+    page = app_client.get('/api/docs')
+    
+    assert page.status_code == 200
+    assert b'unpkg.com' not in page.data
+    for asset in ('swagger-ui.css', 'swagger-ui-bundle.js'):
+        assert app_client.get(f'/api/docs/{asset}').status_code == 200
@@ -0,0 +1,233 @@
+import io
+
+import pytest
+
+import app as backend
+from conftest import error_fields
+
+
+PNG_BYTES = b'\x89PNG\r\n\x1a\n' + b'\x00' * 32
+
+
+def upload_image(client, user, list_id, item_id, data, content_type):
+    return client.post(
+        f'/api/lists/{list_id}/items/{item_id}/image',
+        data={'image': (io.BytesIO(data), 'photo', content_type)},
+        content_type='multipart/form-data',
+        headers=user['headers']
+    )
+
+
+def test_image_upload_rejects_non_image_bytes(client, register, create_list, add_item):
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id)
+    
+    response = upload_image(client, user, list_id, item['id'], b'<script>alert(1)</script>', 'image/png')
+    
+    assert response.status_code == 415
+
+
+def test_image_upload_uses_detected_type_and_is_served_nosniff(client, register, create_list, add_item):
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id)
+    
+    response = upload_image(client, user, list_id, item['id'], PNG_BYTES, 'image/jpeg')
+    
+    assert response.status_code == 200
+    image_url = response.get_json()['item']['image_url']
+    assert image_url.endswith('.png')
+    
+    served = client.get(f"/api/uploads/{image_url.rsplit('/', 1)[1]}")
+    assert served.status_code == 200
+    assert served.headers['X-Content-Type-Options'] == 'nosniff'
+    assert served.data == PNG_BYTES
+
+
+@pytest.mark.parametrize('name', ['   ', 'x' * 256])
+def test_add_item_rejects_blank_or_overlong_name(client, register, create_list, name):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items', json={'name': name, 'category': 'produce'},
+                           headers=user['headers'])
+    
+    assert error_fields(response) == {'name'}
+
+
+def test_add_item_trims_name_and_category(client, register, create_list):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items', json={'name': '  Apples ', 'category': ' Produce '},
+                           headers=user['headers'])
+    
+    assert response.status_code == 201
+    item = response.get_json()['item']
+    assert (item['name'], item['category']) == ('Apples', 'produce')
+
+
+
+@pytest.mark.parametrize('value, expected', [(' Produce ', 'produce'), ('DAIRY', 'dairy'), ('   ', ''), (None, None)])
+def test_normalize_category(value, expected):
+    assert backend.normalize_category(value) == expected
+
+
+@pytest.mark.parametrize('category', ['   ', 'x' * 101])
+def test_add_item_rejects_blank_or_overlong_category(client, register, create_list, category):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items', json={'name': 'Milk', 'category': category},
+                           headers=user['headers'])
+    
+    assert error_fields(response) == {'category'}
+
+
+@pytest.mark.parametrize('category', ['   ', 'x' * 101])
+def test_bulk_add_rejects_blank_or_overlong_category(client, register, create_list, category):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items/bulk', json={'items': [
+        {'name': 'Milk', 'category': 'dairy'},
+        {'name': 'Bread', 'category': category}
+    ]}, headers=user['headers'])
+    
+    assert error_fields(response) == {'items.1.category'}
+    assert client.get(f'/api/lists/{list_id}/items', headers=user['headers']).get_json()['items'] == []
+
+
+def test_bulk_add_normalizes_category(client, register, create_list):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items/bulk', json={'items': [{'name': 'Bread', 'category': ' Bakery '}]},
+                           headers=user['headers'])
+    
+    assert response.status_code == 201
+    assert response.get_json()['items'][0]['category'] == 'bakery'
+
+
+@pytest.mark.parametrize('path, payload', [
+    ('items', {'name': 'Milk', 'category': 'dairy'}),
+    ('items/bulk', {'items': [{'name': 'Milk', 'category': 'dairy'}]})
+])
+def test_add_item_retry_replays_after_quota_is_reached(client, register, create_list, monkeypatch, path, payload):
+    monkeypatch.setattr(backend, 'MAX_ITEMS_PER_LIST', 1)
+    user = register()
+    list_id = create_list(user)
+    headers = {**user['headers'], 'Idempotency-Key': f'add-{path}'}
+    
+    first = client.post(f'/api/lists/{list_id}/{path}', json=payload, headers=headers)
+    retry = client.post(f'/api/lists/{list_id}/{path}', json=payload, headers=headers)
+    other = client.post(f'/api/lists/{list_id}/{path}', json=payload, headers=user['headers'])
+    
+    assert first.status_code == 201
+    assert retry.status_code == 201
+    assert retry.headers.get('Idempotent-Replayed') == 'true'
+    assert retry.get_json() == first.get_json()
+    assert other.status_code == 403
+
+
+def test_image_upload_over_content_limit_reports_that_limit(client, register, create_list, add_item, monkeypatch):
+    monkeypatch.setitem(backend.app.config, 'MAX_CONTENT_LENGTH', 4 * 1024)
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id)
+    
+    response = upload_image(client, user, list_id, item['id'], PNG_BYTES + b'\x00' * 8 * 1024, 'image/png')
+    
+    assert response.status_code == 413
+    assert response.get_json() == {'error': 'Request body must be at most 4 KB'}
+
+
+def test_item_sorts_have_no_placeholders():
+    assert not any('%' in order_sql for order_sql in backend.ITEM_SORTS.values())
+
+
+@pytest.mark.parametrize('query', ['sort=category', 'group_by=category'])
+def test_items_sorted_by_category_follow_store_order(client, register, create_list, add_item, query):
+    user = register()
+    list_id = create_list(user)
+    for category in ('health', 'produce', 'bakery'):
+        add_item(user, list_id, category=category)
+    
+    body = client.get(f'/api/lists/{list_id}/items?{query}', headers=user['headers']).get_json()
+    
+    if 'groups' in body:
+        categories = [group['category'] for group in body['groups']]
+    else:
+        categories = [item['category'] for item in body['items']]
+    assert categories == ['produce', 'bakery', 'health']
+
+
+@pytest.mark.parametrize('priority', ['not-a-priority', 42])
+def test_bulk_update_rejects_invalid_priority(client, register, create_list, add_item, priority):
+    user = register()
+    list_id = create_list(user)
+    first, second = add_item(user, list_id), add_item(user, list_id)
+    
+    response = client.patch(f'/api/lists/{list_id}/items/bulk', json={'items': [
+        {'id': first['id'], 'updates': {'priority': 'high'}},
+        {'id': second['id'], 'updates': {'priority': priority}}
+    ]}, headers=user['headers'])
+    
+    assert error_fields(response) == {'items.1.updates.priority'}
+    items = client.get(f'/api/lists/{list_id}/items', headers=user['headers']).get_json()['items']
+    assert {item['priority'] for item in items} == {backend.DEFAULT_ITEM_PRIORITY}
+
+
+def test_undo_clear_completed_respects_item_quota(client, register, create_list, add_item, monkeypatch):
+    monkeypatch.setattr(backend, 'MAX_ITEMS_PER_LIST', 2)
+    user = register()
+    list_id = create_list(user)
+    for item in (add_item(user, list_id), add_item(user, list_id)):
+        assert client.put(f"/api/lists/{list_id}/items/{item['id']}/toggle", headers=user['headers']).status_code == 200
+    assert client.post(f'/api/lists/{list_id}/items/clear-completed', headers=user['headers']).status_code == 200
+    add_item(user, list_id)
+    
+    response = client.post(f'/api/lists/{list_id}/undo', headers=user['headers'])
+    
+    assert response.status_code == 403
+    assert len(client.get(f'/api/lists/{list_id}/items', headers=user['headers']).get_json()['items']) == 1
+
+
+def test_starter_list_priorities_follow_configured_set(client, register, monkeypatch):
+    monkeypatch.setattr(backend, 'ITEM_PRIORITIES', ['someday', 'soon', 'now'])
+    monkeypatch.setattr(backend, 'DEFAULT_ITEM_PRIORITY', 'soon')
+    user = register()
+    
+    starter = client.get('/api/lists', headers=user['headers']).get_json()['lists'][0]
+    items = client.get(f"/api/lists/{starter['id']}/items", headers=user['headers']).get_json()['items']
+    
+    assert {item['name']: item['priority'] for item in items} == {
+        'Milk': 'soon', 'Bananas': 'someday', 'Chicken Breast': 'now', 'Bread': 'soon', 'Greek Yogurt': 'someday'
+    }
+
+
+def test_merge_category_records_item_history(client, register, create_list, add_item):
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id, category='snacks')
+    
+    response = client.post('/api/groceries/merge-category', json={'from': 'snacks', 'to': 'pantry'},
+                           headers=user['headers'])
+    
+    assert response.status_code == 200
+    assert response.get_json()['items_updated'] == 1
+    history = client.get(f"/api/lists/{list_id}/items/{item['id']}/history", headers=user['headers']).get_json()['history']
+    assert history[0]['change_type'] == 'updated'
+    assert history[0]['changes'] == {'category': {'old': 'snacks', 'new': 'pantry'}}
+
+
+def test_add_item_rejects_unknown_field(client, register, create_list):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.post(f'/api/lists/{list_id}/items', json={'name': 'Milk', 'category': 'dairy', 'priorty': 'high'},
+                           headers=user['headers'])
+    
+    assert response.status_code == 400
+    assert {'field': 'priorty', 'rule': 'unknown', 'message': 'Unknown field.'} in response.get_json()['errors']
@@ -0,0 +1,112 @@
+import pytest
+
+import app as backend
+from conftest import error_fields
+
+
+@pytest.mark.parametrize('name', ['   ', '\t\n', 'x' * 256])
+def test_create_list_rejects_blank_or_overlong_name(client, register, name):
+    user = register()
+    
+    response = client.post('/api/lists', json={'name': name}, headers=user['headers'])
+    
+    assert error_fields(response) == {'name'}
+
+
+def test_create_list_trims_name(client, register):
+    user = register()
+    
+    response = client.post('/api/lists', json={'name': '  Weekend BBQ  '}, headers=user['headers'])
+    
+    assert response.status_code == 201
+    assert response.get_json()['list']['name'] == 'Weekend BBQ'
+
+
+def test_rename_list_rejects_blank_name(client, register, create_list):
+    user = register()
+    list_id = create_list(user)
+    
+    response = client.put(f'/api/lists/{list_id}', json={'name': '   '}, headers=user['headers'])
+    
+    assert error_fields(response) == {'name'}
+
+
+def test_create_list_retry_replays_after_quota_is_reached(client, register, monkeypatch):
+    monkeypatch.setattr(backend, 'MAX_LISTS_PER_USER', 2)
+    user = register()  # Owns the starter list, leaving room for one more
+    headers = {**user['headers'], 'Idempotency-Key': 'create-list-1'}
+    
+    first = client.post('/api/lists', json={'name': 'Groceries'}, headers=headers)
+    retry = client.post('/api/lists', json={'name': 'Groceries'}, headers=headers)
+    other = client.post('/api/lists', json={'name': 'Hardware'}, headers=user['headers'])
+    
+    assert first.status_code == 201
+    assert retry.status_code == 201
+    assert retry.headers.get('Idempotent-Replayed') == 'true'
+    assert retry.get_json() == first.get_json()
+    assert other.status_code == 403
+
+
+def test_deleting_default_list_moves_default_to_another_owned_list(client, register, create_list):
+    user = register()
+    other_id = create_list(user, 'Hardware')
+    default_id = create_list(user, 'Groceries')
+    assert client.put('/api/users/default-list', json={'list_id': default_id},
+                      headers=user['headers']).status_code == 200
+    
+    response = client.delete(f'/api/lists/{default_id}', headers=user['headers'])
+    
+    assert response.status_code == 200
+    default = client.get('/api/users/default-list', headers=user['headers'])
+    assert default.status_code == 200
+    assert default.get_json()['default_list_id'] == other_id
+    assert client.get('/api/auth/me', headers=user['headers']).status_code == 200
+
+
+def test_deleting_only_list_clears_default(client, register, create_list, delete_lists, db):
+    user = register()
+    delete_lists(user)
+    list_id = create_list(user)
+    assert client.put('/api/users/default-list', json={'list_id': list_id},
+                      headers=user['headers']).status_code == 200
+    
+    response = client.delete(f'/api/lists/{list_id}', headers=user['headers'])
+    
+    assert response.status_code == 200
+    db.execute("SELECT default_list_id FROM users WHERE id = %s", (user['id'],))
+    assert db.fetchone()['default_list_id'] is None
+    me = client.get('/api/auth/me', headers=user['headers'])
+    assert me.status_code == 200
+    assert me.get_json()['user']['id'] == user['id']
+    assert client.get('/api/users/default-list', headers=user['headers']).get_json()['default_list'] is None
+
+
+def test_empty_collections_are_arrays(client, register, create_list, delete_lists):
+    user = register()
+    delete_lists(user)
+    
+    assert client.get('/api/lists', headers=user['headers']).get_json()['lists'] == []
+    
+    list_id = create_list(user)
+    
+    assert client.get(f'/api/lists/{list_id}/items', headers=user['headers']).get_json()['items'] == []
+    assert client.get(f'/api/lists/{list_id}', headers=user['headers']).get_json()['list']['items'] == []
+    assert client.get(f'/api/lists/{list_id}/shares', headers=user['headers']).get_json()['shares'] == []
+
+
+@pytest.mark.parametrize('merge', [False, True])
+def test_deleting_a_list_notifies_collaborators(client, register, create_list, share_list, merge):
+    owner, member = register(), register()
+    target_id = create_list(owner, 'Target')
+    source_id = create_list(owner, 'Source')
+    share_list(owner, member, source_id)
+    
+    if merge:
+        response = client.post(f'/api/lists/{target_id}/merge', json={'source_list_id': source_id, 'delete_source': True},
+                               headers=owner['headers'])
+    else:
+        response = client.delete(f'/api/lists/{source_id}', headers=owner['headers'])
+    
+    assert response.status_code == 200
+    notifications = client.get('/api/notifications?type=list_deleted', headers=member['headers']).get_json()['notifications']
+    assert [notification['data'] for notification in notifications] == [{'list_id': source_id}]
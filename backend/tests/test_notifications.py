@@ -0,0 +1,92 @@
+import pytest
+
+import app as backend
+
+
+@pytest.fixture
+def invited(client, register, create_list):
+    """A user with one unread share invitation"""
+    owner, user = register(), register()
+    list_id = create_list(owner)
+    response = client.post(f'/api/lists/{list_id}/invite', json={'username': user['username']},
+                           headers=owner['headers'])
+    assert response.status_code == 200
+    
+    notifications = client.get('/api/notifications', headers=user['headers']).get_json()['notifications']
+    assert len(notifications) == 1
+    return user, notifications[0], owner
+
+
+def test_unread_count(client, invited):
+    user, notification, _ = invited
+    
+    assert client.get('/api/notifications/unread-count', headers=user['headers']).get_json() == {'unread_count': 1}
+    
+    client.put(f"/api/notifications/{notification['id']}/read", headers=user['headers'])
+    assert client.get('/api/notifications/unread-count', headers=user['headers']).get_json() == {'unread_count': 0}
+
+
+def test_get_notification(client, invited):
+    user, notification, owner = invited
+    
+    response = client.get(f"/api/notifications/{notification['id']}", headers=user['headers'])
+    assert response.status_code == 200
+    assert response.get_json()['notification']['type'] == 'share_invitation'
+    
+    # Other users' notifications look like missing ones
+    assert client.get(f"/api/notifications/{notification['id']}", headers=owner['headers']).status_code == 404
+
+
+def test_delete_notification(client, invited):
+    user, notification, owner = invited
+    path = f"/api/notifications/{notification['id']}"
+    
+    assert client.delete(path, headers=owner['headers']).status_code == 404
+    assert client.delete(path, headers=user['headers']).status_code == 200
+    assert client.get(path, headers=user['headers']).status_code == 404
+    assert client.delete(path, headers=user['headers']).status_code == 404
+
+
+@pytest.mark.parametrize('data', ['{"list_id": 1', '{"list_id": 1}', ['list_id', 1], 42])
+def test_create_notification_rejects_non_object_data(db, register, data):
+    user = register()
+    
+    with pytest.raises(ValueError):
+        backend.create_notification(db, user['id'], 'announcement', 'Hello', 'Message', data)
+    
+    db.execute("SELECT COUNT(*) AS count FROM notifications WHERE user_id = %s", (user['id'],))
+    assert db.fetchone()['count'] == 0
+
+
+def test_create_notification_stores_object_data(client, db, register):
+    user = register()
+    
+    backend.create_notification(db, user['id'], 'announcement', 'Hello', 'Message', {'list_id': 7})
+    db.connection.commit()
+    
+    notifications = client.get('/api/notifications', headers=user['headers']).get_json()['notifications']
+    assert notifications[0]['data'] == {'list_id': 7}
+
+
+def test_digest_is_committed_before_it_is_emailed(client, db, register, create_list, add_item, outbox, monkeypatch):
+    monkeypatch.setattr(backend, 'DIGEST_EMAIL_ENABLED', True)
+    user = register()
+    add_item(user, create_list(user, 'Groceries'), 'Milk')
+    db.execute("UPDATE users SET digest_enabled = TRUE WHERE id = %s", (user['id'],))
+    db.connection.commit()
+    
+    def send(to, subject, body):
+        # The email only goes out once the digest is recorded, so a retry can't send it twice
+        with backend.get_db_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute("SELECT digest_last_sent_at FROM users WHERE id = %s", (user['id'],))
+                assert cur.fetchone()[0] is not None
+        outbox.append({'to': to, 'subject': subject, 'body': body})
+    monkeypatch.setattr(backend.mailer, 'send', send)
+    
+    backend.send_digests(backend.get_db_connection)
+    backend.send_digests(backend.get_db_connection)
+    
+    assert [email['subject'] for email in outbox if email['to'] == user['email']] == ['Items left on your shopping lists']
+    notifications = client.get('/api/notifications?type=digest', headers=user['headers']).get_json()['notifications']
+    assert len(notifications) == 1
@@ -0,0 +1,24 @@
+def invite(client, inviter, list_id, username, permission='read'):
+    return client.post(f'/api/lists/{list_id}/invite', json={'username': username, 'permission': permission},
+                       headers=inviter['headers'])
+
+
+def test_owner_cannot_invite_themselves(client, register, create_list):
+    owner = register()
+    list_id = create_list(owner)
+    
+    for username in (owner['username'], owner['username'].upper()):
+        response = invite(client, owner, list_id, username)
+        assert response.status_code == 400
+        assert response.get_json()['error'] == 'Cannot invite yourself'
+
+
+def test_admin_cannot_invite_the_owner(client, register, create_list, share_list):
+    owner, admin = register(), register()
+    list_id = create_list(owner)
+    share_list(owner, admin, list_id, permission='admin')
+    
+    response = invite(client, admin, list_id, owner['username'])
+    
+    assert response.status_code == 400
+    assert response.get_json()['error'] == 'The list owner already has access'
@@ -0,0 +1,29 @@
+def test_sync_picks_up_write_committed_after_server_time(client, db, register, create_list, add_item):
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id, 'Milk')
+    db.connection.commit()
+    
+    # The update's transaction starts (and stamps updated_at) before the sync but commits after it
+    db.execute("UPDATE shopping_list_items SET name = 'Oat milk' WHERE id = %s", (item['id'],))
+    first = client.get('/api/sync', headers=user['headers']).get_json()
+    db.connection.commit()
+    
+    response = client.get('/api/sync', query_string={'since': first['server_time']}, headers=user['headers'])
+    
+    assert response.status_code == 200
+    synced = {synced['id']: synced['name'] for synced in response.get_json()['items']}
+    assert synced[item['id']] == 'Oat milk'
+
+
+def test_sync_without_since_returns_everything(client, register, create_list, add_item):
+    user = register()
+    list_id = create_list(user)
+    item = add_item(user, list_id)
+    
+    body = client.get('/api/sync', headers=user['headers']).get_json()
+    
+    # The starter list created at registration comes along too
+    assert list_id in [synced['id'] for synced in body['lists']]
+    assert item['id'] in [synced['id'] for synced in body['items']]
+    assert body['deleted'] == {'lists': [], 'items': [], 'shares': [], 'notifications': []}
@@ -0,0 +1,103 @@
+import json
+
+import pytest
+
+import app as backend
+from webhooks import UnsafeWebhookURL, WebhookDispatcher, check_webhook_url
+from conftest import error_fields
+
+
+@pytest.mark.parametrize('url', [
+    'http://127.0.0.1/hook',
+    'http://localhost:8080/hook',
+    'http://[::1]/hook',
+    'http://10.1.2.3/hook',
+    'http://192.168.0.10/hook',
+    'http://169.254.169.254/latest/meta-data/',
+    'http://0.0.0.0/hook'
+])
+def test_check_webhook_url_rejects_internal_hosts(url):
+    with pytest.raises(UnsafeWebhookURL):
+        check_webhook_url(url)
+
+
+def test_check_webhook_url_allows_public_addresses():
+    check_webhook_url('https://93.184.216.34/hook')
+
+
+def test_check_webhook_url_allow_private_escape_hatch():
+    check_webhook_url('http://127.0.0.1/hook', allow_private=True)
+
+
+@pytest.mark.parametrize('url', ['http://127.0.0.1/hook', 'http://169.254.169.254/'])
+def test_create_webhook_rejects_internal_url(client, register, url):
+    user = register()
+    
+    response = client.post('/api/webhooks', json={'url': url, 'events': ['item.created']},
+                           headers=user['headers'])
+    
+    assert error_fields(response) == {'url'}
+
+
+def test_update_webhook_rejects_internal_url(client, register):
+    user = register()
+    created = client.post('/api/webhooks', json={'url': 'https://93.184.216.34/hook', 'events': ['item.created']},
+                          headers=user['headers'])
+    assert created.status_code == 201
+    
+    response = client.put(f"/api/webhooks/{created.get_json()['webhook']['id']}", json={'url': 'http://10.0.0.5/hook'},
+                          headers=user['headers'])
+    
+    assert error_fields(response) == {'url'}
+
+
+def queue_delivery(db, user, url):
+    """Insert a webhook directly, bypassing the URL check, with one due delivery"""
+    db.execute("""
+        INSERT INTO webhooks (user_id, url, secret, events)
+        VALUES (%s, %s, 'secret', ARRAY['item.created']) RETURNING id
+    """, (user['id'], url))
+    webhook_id = db.fetchone()['id']
+    db.execute("""
+        INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+        VALUES (%s, 'item.created', %s) RETURNING id
+    """, (webhook_id, json.dumps({'id': 1})))
+    delivery_id = db.fetchone()['id']
+    db.connection.commit()
+    return delivery_id
+
+
+def test_delivery_to_internal_host_is_not_sent(db, register, monkeypatch):
+    # A webhook saved before the check (or whose DNS changed since) is blocked at send time
+    delivery_id = queue_delivery(db, register(), 'http://127.0.0.1:5432/')
+    
+    dispatcher = WebhookDispatcher(timeout=1, max_attempts=5, base_delay=30, batch_size=100)
+    monkeypatch.setattr(dispatcher, 'send', lambda *args: pytest.fail('internal webhook was sent'))
+    dispatcher.deliver_pending(backend.get_db_connection)
+    
+    db.execute("SELECT status, attempts, last_error FROM webhook_deliveries WHERE id = %s", (delivery_id,))
+    delivery = db.fetchone()
+    assert delivery['status'] == 'pending'
+    assert delivery['attempts'] == 1
+    assert delivery['last_error'] == 'Host resolves to a private or reserved address'
+
+
+def test_delivery_is_claimed_and_committed_before_sending(db, register, monkeypatch):
+    delivery_id = queue_delivery(db, register(), 'https://93.184.216.34/hook')
+    seen = {}
+    
+    def send(url, secret, sent_id, event_type, payload):
+        # Another connection can lock the row, so no transaction is holding it during the POST
+        with backend.get_db_connection() as conn:
+            with conn.cursor() as cur:
+                cur.execute("SELECT status FROM webhook_deliveries WHERE id = %s FOR UPDATE NOWAIT", (sent_id,))
+                seen[sent_id] = cur.fetchone()[0]
+        return 204
+    
+    dispatcher = WebhookDispatcher(timeout=1, max_attempts=5, base_delay=30, batch_size=100)
+    monkeypatch.setattr(dispatcher, 'send', send)
+    dispatcher.deliver_pending(backend.get_db_connection)
+    
+    assert seen[delivery_id] == 'sending'
+    db.execute("SELECT status, response_status, last_error FROM webhook_deliveries WHERE id = %s", (delivery_id,))
+    assert db.fetchone() == {'status': 'delivered', 'response_status': 204, 'last_error': None}
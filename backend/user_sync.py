@@ -152,8 +152,8 @@ class UserSyncManager:
             with self.conn.cursor(cursor_factory=RealDictCursor) as cur:
                 # Create user with Authentik provider (no password_hash)
                 cur.execute("""
-                    INSERT INTO users (username, email, password_hash, authentik_sub, auth_provider, linked_at, last_oidc_login)
-                    VALUES (%s, %s, NULL, %s, 'authentik', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+                    INSERT INTO users (username, email, password_hash, authentik_sub, auth_provider, email_verified, linked_at, last_oidc_login)
+                    VALUES (%s, %s, NULL, %s, 'authentik', TRUE, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
                     RETURNING id, username, email, authentik_sub, auth_provider, created_at
                 """, (username, email, authentik_sub))
                 
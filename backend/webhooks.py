@@ -0,0 +1,169 @@
+#!/usr/bin/env python3
+"""
+Outgoing Webhooks
+Events are queued in webhook_deliveries inside the transaction that caused them,
+then signed and POSTed by a background job with retry and exponential backoff
+"""
+
+import os
+import hmac
+import json
+import socket
+import hashlib
+import ipaddress
+from datetime import timedelta
+from urllib.parse import urlparse
+
+import requests
+
+# Event types a webhook can subscribe to
+WEBHOOK_EVENTS = (
+    'item.created', 'item.updated', 'item.deleted', 'item.restored',
+    'list.updated', 'list.deleted'
+)
+
+
+def sign_payload(secret: str, body: bytes) -> str:
+    """HMAC-SHA256 of the raw request body, sent as X-Webhook-Signature"""
+    return 'sha256=' + hmac.new(secret.encode(), body, hashlib.sha256).hexdigest()
+
+
+class UnsafeWebhookURL(ValueError):
+    """The URL's host can't be resolved or resolves to an address webhooks may not reach"""
+
+
+def check_webhook_url(url: str, allow_private: bool = False) -> None:
+    """
+    Resolve the URL's host and raise UnsafeWebhookURL unless every address it resolves to
+    is public, so webhooks can't be pointed at loopback, private, link-local or reserved hosts
+    """
+    parsed = urlparse(url)
+    if not parsed.hostname:
+        raise UnsafeWebhookURL('URL has no host')
+
+    try:
+        port = parsed.port or (443 if parsed.scheme == 'https' else 80)
+        addresses = {info[4][0] for info in socket.getaddrinfo(parsed.hostname, port, type=socket.SOCK_STREAM)}
+    except (socket.gaierror, UnicodeError, ValueError):
+        raise UnsafeWebhookURL('Host could not be resolved')
+
+    if allow_private:
+        return
+    for address in addresses:
+        ip = ipaddress.ip_address(address.split('%')[0])
+        if not ip.is_global or ip.is_multicast:
+            raise UnsafeWebhookURL('Host resolves to a private or reserved address')
+
+
+def describe_failure(error: Exception) -> str:
+    """Generic reason stored in the delivery log; raw exception text can reveal internal details"""
+    if isinstance(error, UnsafeWebhookURL):
+        return str(error)
+    if isinstance(error, requests.Timeout):
+        return 'Request timed out'
+    if isinstance(error, requests.ConnectionError):
+        return 'Connection failed'
+    return 'Request failed'
+
+
+class WebhookDispatcher:
+    """Delivers due webhook_deliveries rows; registered as a BackgroundJobs job"""
+
+    def __init__(self, timeout: float, max_attempts: int, base_delay: float, batch_size: int,
+                 allow_private: bool = False):
+        self.timeout = timeout
+        self.max_attempts = max_attempts
+        self.base_delay = base_delay
+        self.batch_size = batch_size
+        self.allow_private = allow_private
+
+    def check_url(self, url: str) -> None:
+        """Raise UnsafeWebhookURL for URLs this dispatcher won't deliver to"""
+        check_webhook_url(url, self.allow_private)
+
+    def send(self, url: str, secret: str, delivery_id: int, event_type: str, payload) -> int:
+        """POST one event and return the response status; raises requests.RequestException on network errors"""
+        body = json.dumps(payload, separators=(',', ':')).encode()
+        response = requests.post(url, data=body, timeout=self.timeout, allow_redirects=False, headers={
+            'Content-Type': 'application/json',
+            'User-Agent': 'shopping-list-webhooks',
+            'X-Webhook-Event': event_type,
+            'X-Webhook-Delivery': str(delivery_id),
+            'X-Webhook-Signature': sign_payload(secret, body)
+        })
+        return response.status_code
+
+    def deliver_pending(self, get_connection) -> int:
+        """
+        Attempt every due delivery once; returns the number attempted. Deliveries are
+        claimed and committed first so no transaction or lock is held during the POSTs.
+        """
+        # A claim is a lease: deliveries still 'sending' after it (worker died mid-batch) are claimed again
+        lease = timedelta(seconds=self.timeout * self.batch_size + 60)
+        with get_connection() as conn:
+            with conn.cursor() as cur:
+                # Webhooks that were disabled keep their pending deliveries until re-enabled
+                cur.execute("""
+                    UPDATE webhook_deliveries d
+                    SET status = 'sending', next_attempt_at = CURRENT_TIMESTAMP + %s::interval
+                    FROM webhooks w
+                    WHERE w.id = d.webhook_id AND d.id IN (
+                        SELECT pd.id
+                        FROM webhook_deliveries pd
+                        JOIN webhooks pw ON pw.id = pd.webhook_id
+                        WHERE pd.status IN ('pending', 'sending')
+                          AND pd.next_attempt_at <= CURRENT_TIMESTAMP AND pw.enabled
+                        ORDER BY pd.next_attempt_at
+                        LIMIT %s
+                        FOR UPDATE OF pd SKIP LOCKED
+                    )
+                    RETURNING d.id, d.event_type, d.payload, d.attempts, w.url, w.secret
+                """, (lease, self.batch_size))
+                deliveries = cur.fetchall()
+            conn.commit()
+
+        for delivery_id, event_type, payload, attempts, url, secret in deliveries:
+            attempts += 1
+            response_status, error = None, None
+            try:
+                # Checked again on every attempt since DNS can change after the webhook was saved
+                self.check_url(url)
+                response_status = self.send(url, secret, delivery_id, event_type, payload)
+                if not 200 <= response_status < 300:
+                    error = f'HTTP {response_status}'
+            except (UnsafeWebhookURL, requests.RequestException) as e:
+                print(f"Webhook delivery {delivery_id} error: {e}")
+                error = describe_failure(e)
+
+            if error is None:
+                status, retry_in = 'delivered', None
+            elif attempts >= self.max_attempts:
+                status, retry_in = 'failed', None
+            else:
+                status, retry_in = 'pending', timedelta(seconds=self.base_delay * 2 ** (attempts - 1))
+
+            with get_connection() as conn:
+                with conn.cursor() as cur:
+                    cur.execute("""
+                        UPDATE webhook_deliveries
+                        SET status = %s, attempts = %s, response_status = %s, last_error = %s,
+                            next_attempt_at = COALESCE(CURRENT_TIMESTAMP + %s::interval, next_attempt_at),
+                            delivered_at = CASE WHEN %s = 'delivered' THEN CURRENT_TIMESTAMP END
+                        WHERE id = %s
+                    """, (status, attempts, response_status, error, retry_in, status, delivery_id))
+                conn.commit()
+
+        return len(deliveries)
+
+def create_webhook_dispatcher() -> WebhookDispatcher:
+    """
+    Factory function to create the dispatcher from environment configuration
+    """
+    return WebhookDispatcher(
+        timeout=float(os.getenv('WEBHOOK_TIMEOUT_SECONDS', 5)),
+        max_attempts=int(os.getenv('WEBHOOK_MAX_ATTEMPTS', 5)),
+        base_delay=float(os.getenv('WEBHOOK_RETRY_DELAY_SECONDS', 30)),
+        batch_size=int(os.getenv('WEBHOOK_BATCH_SIZE', 20)),
+        # Only for local development against receivers on this machine or network
+        allow_private=os.getenv('WEBHOOK_ALLOW_PRIVATE_ADDRESSES', 'false').lower() == 'true'
+    )
@@ -54,8 +54,17 @@ async function apiRequest(endpoint, options = {}) {
         const response = await fetch(url, config);
         const data = await response.json();
 
+        // The backend renews tokens that are close to expiring
+        const refreshedToken = response.headers.get('X-Refreshed-Token');
+        if (refreshedToken) {
+            authToken = refreshedToken;
+            localStorage.setItem('authToken', authToken);
+        }
+
         if (!response.ok) {
-            throw new Error(data.error || `HTTP ${response.status}`);
+            const error = new Error(data.error || `HTTP ${response.status}`);
+            error.status = response.status;
+            throw error;
         }
 
         return data;
@@ -1611,16 +1620,22 @@ async function addItem(event) {
     }
 
     try {
-        const response = await apiRequest(`/lists/${currentListId}/items`, {
-            method: 'POST',
-            body: JSON.stringify({
-                name,
-                quantity,
-                category,
-                priority,
-                notes
-            })
+        const body = JSON.stringify({
+            name,
+            quantity,
+            category,
+            priority,
+            notes
         });
+        let response;
+        try {
+            response = await apiRequest(`/lists/${currentListId}/items`, { method: 'POST', body });
+        } catch (error) {
+            // 409: an uncompleted item with this name is already on the list
+            if (error.status !== 409) throw error;
+            if (!confirm(`"${name}" is already on the list. Add it again?`)) return;
+            response = await apiRequest(`/lists/${currentListId}/items?force=true`, { method: 'POST', body });
+        }
 
         const newItem = response.item;
         